@@ -8,6 +8,8 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	// Embed the time zone database for blackout windows.
+	_ "time/tzdata"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -15,6 +17,7 @@ import (
 
 	"github.com/bigkevmcd/tekton-polling-operator/pkg/apis"
 	"github.com/bigkevmcd/tekton-polling-operator/pkg/controller"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
 	"github.com/bigkevmcd/tekton-polling-operator/version"
 	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 
@@ -27,6 +30,7 @@ import (
 	"github.com/spf13/pflag"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/discovery"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -42,6 +46,12 @@ var (
 )
 var log = logf.Log.WithName("cmd")
 
+var (
+	pollJitter       = pflag.Duration("poll-jitter", 0, "The maximum delay added to the frequency of each Repository to spread out polls")
+	dryRun           = pflag.Bool("dry-run", false, "Render the runs for all Repositories, and record them in the status, without creating them")
+	tektonAPIVersion = pflag.String("tekton-api-version", string(pipelines.AutoAPIVersion), "The Tekton API version for created runs, v1beta1 or v1, or auto to use the newest version that the cluster serves")
+)
+
 func printVersion() {
 	log.Info(fmt.Sprintf("Operator Version: %s", version.Version))
 	log.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
@@ -128,8 +138,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	apiVersion, err := runAPIVersion(cfg)
+	if err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+	log.Info("Creating Tekton runs", "apiVersion", apiVersion)
+
 	// Setup all Controllers
-	if err := controller.AddToManager(mgr); err != nil {
+	if err := controller.AddToManager(mgr, controller.Options{Jitter: *pollJitter, APIVersion: apiVersion, DryRun: *dryRun}); err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
@@ -146,6 +163,23 @@ func main() {
 	}
 }
 
+// runAPIVersion returns the configured Tekton API version, or the newest
+// version served by the cluster.
+func runAPIVersion(cfg *rest.Config) (pipelines.APIVersion, error) {
+	switch v := pipelines.APIVersion(*tektonAPIVersion); v {
+	case pipelines.V1Beta1, pipelines.V1:
+		return v, nil
+	case pipelines.AutoAPIVersion:
+	default:
+		return "", fmt.Errorf("unknown Tekton API version %#v", *tektonAPIVersion)
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	return pipelines.DetectAPIVersion(dc)
+}
+
 // addMetrics will create the Services and Service Monitors to allow the operator export the metrics by using
 // the Prometheus operator
 func addMetrics(ctx context.Context, cfg *rest.Config) {
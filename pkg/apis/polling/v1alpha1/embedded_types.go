@@ -0,0 +1,57 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+// The generated schema for the Tekton types can't describe fields that are
+// either a string or an array e.g. param values, so the wrapped Tekton types
+// are excluded from the schema, and fields using these types should preserve
+// unknown fields.
+
+// PipelineRunSpec wraps a Tekton PipelineRunSpec.
+type PipelineRunSpec struct {
+	pipelinev1.PipelineRunSpec `json:"-"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (s PipelineRunSpec) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.PipelineRunSpec)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (s *PipelineRunSpec) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &s.PipelineRunSpec)
+}
+
+// PipelineSpec wraps a Tekton PipelineSpec.
+type PipelineSpec struct {
+	pipelinev1.PipelineSpec `json:"-"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (s PipelineSpec) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.PipelineSpec)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (s *PipelineSpec) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &s.PipelineSpec)
+}
+
+// TaskSpec wraps a Tekton TaskSpec.
+type TaskSpec struct {
+	pipelinev1.TaskSpec `json:"-"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (s TaskSpec) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.TaskSpec)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (s *TaskSpec) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &s.TaskSpec)
+}
@@ -5,6 +5,7 @@ import (
 
 	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -17,6 +18,45 @@ const (
 	GitLab RepoType = "gitlab"
 )
 
+// TriggerAnnotation can be set on a Repository to force a PipelineRun for the
+// current commit, the value is a token that is recorded in the status once the
+// trigger has been handled, changing the value will trigger another run.
+const TriggerAnnotation = "polling.tekton.dev/trigger-now"
+
+const (
+	// RepositoryLabel is applied to created runs with the name of the
+	// Repository.
+	RepositoryLabel = "polling.tekton.dev/repository"
+	// RepositoryNamespaceLabel is applied to created runs with the namespace of
+	// the Repository.
+	RepositoryNamespaceLabel = "polling.tekton.dev/repository-namespace"
+	// SHALabel is applied to created runs with the SHA of the commit that
+	// triggered the run.
+	SHALabel = "polling.tekton.dev/sha"
+	// RefLabel is applied to created runs with the ref that was polled.
+	RefLabel = "polling.tekton.dev/ref"
+	// TriggerIDLabel is applied to created runs with the ID of the
+	// PendingTrigger that the run was created for.
+	TriggerIDLabel = "polling.tekton.dev/trigger-id"
+	// PipelineLabel is applied to runs created for one of the Repository's
+	// Pipelines with the name of the PipelineTarget.
+	PipelineLabel = "polling.tekton.dev/pipeline"
+	// TemplateResourceLabel is applied to resources created from a
+	// TriggerTemplate with the index of the resource in the template, along
+	// with the TriggerIDLabel, this identifies the resources that were created
+	// before a failure.
+	TemplateResourceLabel = "polling.tekton.dev/template-resource"
+	// ReportedStatusAnnotation is applied to runs with the state of the most
+	// recent commit status that was reported for the run.
+	ReportedStatusAnnotation = "polling.tekton.dev/reported-status"
+	// CheckRunIDAnnotation is applied to runs with the ID of the GitHub check
+	// run that reports the run.
+	CheckRunIDAnnotation = "polling.tekton.dev/check-run-id"
+	// ReportedCheckAnnotation is applied to runs with the state of the run
+	// when the check run was most recently reported.
+	ReportedCheckAnnotation = "polling.tekton.dev/reported-check"
+)
+
 // RepositorySpec defines a repository to poll.
 type RepositorySpec struct {
 	URL       string           `json:"url"`
@@ -24,24 +64,271 @@ type RepositorySpec struct {
 	Auth      *AuthSecret      `json:"auth,omitempty"`
 	Type      RepoType         `json:"type,omitempty"`
 	Frequency *metav1.Duration `json:"frequency,omitempty"`
-	Pipeline  PipelineRef      `json:"pipelineRef"`
+	// Jitter is the maximum delay added to the frequency to spread out polls,
+	// this overrides the default configured for the operator.
+	Jitter   *metav1.Duration `json:"jitter,omitempty"`
+	Pipeline PipelineRef      `json:"pipelineRef,omitempty"`
+	// PipelineSpec is an embedded Pipeline to execute instead of the named
+	// Pipeline in the PipelineRef.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	PipelineSpec *PipelineSpec `json:"pipelineSpec,omitempty"`
+	// PipelineRunTemplate is used as the basis for created PipelineRuns, the
+	// values from the PipelineRef are applied on top of it.
+	PipelineRunTemplate *PipelineRunTemplate `json:"pipelineRunTemplate,omitempty"`
+	// Task is a Task to execute as a TaskRun instead of a Pipeline.
+	Task *TaskRef `json:"taskRef,omitempty"`
+	// TaskSpec is an embedded Task to execute instead of the named Task in the
+	// TaskRef.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	TaskSpec *TaskSpec `json:"taskSpec,omitempty"`
+	// Pipelines are executed for each change, this is used instead of the
+	// PipelineRef, to run several pipelines from a single poll.
+	Pipelines []PipelineTarget `json:"pipelines,omitempty"`
+	// TriggerTemplate is a Tekton Triggers TriggerTemplate that is rendered
+	// with the Bindings, this is used instead of a pipeline or task.
+	TriggerTemplate *TriggerTemplateRef `json:"triggerTemplateRef,omitempty"`
+	// Bindings are evaluated from the commit to provide the params for the
+	// TriggerTemplate.
+	Bindings []Param `json:"bindings,omitempty"`
+	// TektonAPIVersion is the version of the Tekton API used for created runs,
+	// this overrides the version configured for the operator.
+	// +kubebuilder:validation:Enum=v1beta1;v1
+	TektonAPIVersion string `json:"tektonAPIVersion,omitempty"`
+	// ConcurrencyPolicy determines what happens when a change is detected
+	// while runs created for a previous change are still running, this
+	// defaults to Allow.
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+	// SuccessfulRunsHistoryLimit is the number of successful runs created for
+	// the Repository to keep, older runs are deleted.
+	// +kubebuilder:validation:Minimum=0
+	SuccessfulRunsHistoryLimit *int32 `json:"successfulRunsHistoryLimit,omitempty"`
+	// FailedRunsHistoryLimit is the number of failed runs created for the
+	// Repository to keep, older runs are deleted.
+	// +kubebuilder:validation:Minimum=0
+	FailedRunsHistoryLimit *int32 `json:"failedRunsHistoryLimit,omitempty"`
+	// ReportStatus enables reporting the status of runs to the Git hosting
+	// service as commit statuses.
+	ReportStatus *ReportStatus `json:"reportStatus,omitempty"`
+	// ReportChecks enables reporting runs as GitHub check runs, with a summary
+	// of the TaskRuns, this requires a GitHub App.
+	ReportChecks *ReportChecks `json:"reportChecks,omitempty"`
+	// SourceWorkspace is a workspace that is bound to a new volume for each
+	// run, this is added to the runs unless a workspace with the same name is
+	// already bound.
+	SourceWorkspace *SourceWorkspace `json:"sourceWorkspace,omitempty"`
+	// DryRun renders the runs for each change and records them in the status,
+	// without creating them.
+	DryRun bool `json:"dryRun,omitempty"`
+	// BlackoutWindows are periods during which changes are recorded, but
+	// PipelineRuns are not created.
+	BlackoutWindows []BlackoutWindow `json:"blackoutWindows,omitempty"`
+	// BlackoutPolicy determines what happens to changes detected during a
+	// blackout window, this defaults to Defer.
+	BlackoutPolicy BlackoutPolicy `json:"blackoutPolicy,omitempty"`
+}
+
+// DefaultStatusContext is the context that commit statuses are reported with
+// if none is configured.
+const DefaultStatusContext = "tekton-polling-operator"
+
+// ReportStatus configures the commit statuses that are reported for runs.
+type ReportStatus struct {
+	// Context identifies the status, this defaults to
+	// "tekton-polling-operator".
+	Context string `json:"context,omitempty"`
+	// TargetURL is a Go template for the URL that the status links to, the
+	// template is executed with the Kind, Name, Namespace and SHA of the run,
+	// e.g. https://dashboard.example.com/#/namespaces/{{.Namespace}}/pipelineruns/{{.Name}}
+	TargetURL string `json:"targetURL,omitempty"`
+}
+
+// GetContext returns the configured context, or the default context.
+func (r *ReportStatus) GetContext() string {
+	if r.Context != "" {
+		return r.Context
+	}
+	return DefaultStatusContext
+}
+
+// ReportChecks configures the GitHub check runs that are created for runs.
+type ReportChecks struct {
+	// Name is the name of the check run, this defaults to
+	// "tekton-polling-operator".
+	Name string `json:"name,omitempty"`
+	// DetailsURL is a Go template for the URL that the check run links to,
+	// this is executed in the same way as the ReportStatus TargetURL.
+	DetailsURL string `json:"detailsURL,omitempty"`
+	// AppSecretRef is a Secret with the "appID", "installationID" and
+	// "privateKey" of the GitHub App that creates the check runs.
+	AppSecretRef corev1.SecretReference `json:"appSecretRef"`
+}
+
+// GetName returns the configured name, or the default name.
+func (r *ReportChecks) GetName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return DefaultStatusContext
+}
+
+// DefaultSourceWorkspaceName is the name of the SourceWorkspace if none is
+// configured.
+const DefaultSourceWorkspaceName = "source"
+
+// SourceWorkspace configures the volume that is created for each run.
+type SourceWorkspace struct {
+	// Name is the name of the workspace, this defaults to "source".
+	Name string `json:"name,omitempty"`
+	// Size is the requested size of the volume, this defaults to 1Gi.
+	Size *resource.Quantity `json:"size,omitempty"`
+	// StorageClassName is the storage class for the volume, this defaults to
+	// the cluster's default storage class.
+	StorageClassName string `json:"storageClassName,omitempty"`
+}
+
+// GetName returns the configured name, or the default name.
+func (w *SourceWorkspace) GetName() string {
+	if w.Name != "" {
+		return w.Name
+	}
+	return DefaultSourceWorkspaceName
+}
+
+// GetSize returns the configured size, or the default size.
+func (w *SourceWorkspace) GetSize() resource.Quantity {
+	if w.Size != nil {
+		return *w.Size
+	}
+	return resource.MustParse("1Gi")
+}
+
+// ConcurrencyPolicy defines how overlapping runs are handled.
+// +kubebuilder:validation:Enum=Allow;Forbid;Replace;Queue
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent creates runs regardless of the active runs.
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+	// ForbidConcurrent does not create a run if there are active runs.
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+	// ReplaceConcurrent cancels the active runs before creating a run.
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+	// QueueConcurrent creates a run for the latest change once the active runs
+	// have completed.
+	QueueConcurrent ConcurrencyPolicy = "Queue"
+)
+
+// BlackoutPolicy defines how changes detected during a blackout are handled.
+// +kubebuilder:validation:Enum=Defer;Drop
+type BlackoutPolicy string
+
+const (
+	// BlackoutDefer creates a PipelineRun for the latest commit once the
+	// blackout window ends.
+	BlackoutDefer BlackoutPolicy = "Defer"
+	// BlackoutDrop records the change, but does not create a PipelineRun.
+	BlackoutDrop BlackoutPolicy = "Drop"
+)
+
+// BlackoutWindow is a recurring period during which PipelineRuns are not
+// created.
+type BlackoutWindow struct {
+	// Start is a standard cron expression e.g. "0 18 * * 5" for the start of
+	// the window.
+	Start string `json:"start"`
+	// Duration is how long the window lasts from each start.
+	Duration metav1.Duration `json:"duration"`
+	// TimeZone is the IANA time zone for the Start expression, this defaults
+	// to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 // PipelineRef links to the Pipeline to execute.
 type PipelineRef struct {
-	Name               string                               `json:"name"`
-	Namespace          string                               `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// NamespaceSelector selects namespaces to execute the Pipeline in, a run
+	// is created in every matching namespace, this is used instead of the
+	// Namespace.
+	NamespaceSelector  *metav1.LabelSelector                `json:"namespaceSelector,omitempty"`
 	ServiceAccountName string                               `json:"serviceAccountName,omitempty"`
 	Params             []Param                              `json:"params,omitempty"`
 	Resources          []pipelinev1.PipelineResourceBinding `json:"resources,omitempty"`
 	Workspaces         []pipelinev1.WorkspaceBinding        `json:"workspaces,omitempty"`
+	// Bundle is the OCI image reference of a Tekton bundle that contains the
+	// named Pipeline.
+	Bundle string `json:"bundle,omitempty"`
+	// Resolver is the Tekton remote resolver used to fetch the Pipeline e.g.
+	// git, bundles, hub or cluster, this is used instead of the name.
+	Resolver string `json:"resolver,omitempty"`
+	// ResolverParams are passed to the Resolver to locate the Pipeline.
+	ResolverParams []ResolverParam `json:"resolverParams,omitempty"`
+}
+
+// PipelineTarget is one of the pipelines executed for a Repository.
+type PipelineTarget struct {
+	// Name identifies the target, this is applied to the created runs with
+	// the PipelineLabel.
+	Name        string      `json:"name"`
+	PipelineRef PipelineRef `json:"pipelineRef"`
+	// Filter is a CEL expression that is evaluated with the commit, a run is
+	// only created for the target if it evaluates to true.
+	Filter string `json:"filter,omitempty"`
+}
+
+// ResolverParam is a param passed to a Tekton remote resolver.
+type ResolverParam struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// TaskRef links to the Task to execute.
+type TaskRef struct {
+	Name               string                        `json:"name,omitempty"`
+	Namespace          string                        `json:"namespace,omitempty"`
+	ServiceAccountName string                        `json:"serviceAccountName,omitempty"`
+	Params             []Param                       `json:"params,omitempty"`
+	Workspaces         []pipelinev1.WorkspaceBinding `json:"workspaces,omitempty"`
+}
+
+// TriggerTemplateRef links to the TriggerTemplate to render.
+type TriggerTemplateRef struct {
+	Name string `json:"name"`
+	// Namespace is the namespace of the TriggerTemplate, the resources are
+	// created in this namespace, this defaults to the Repository's namespace.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// PipelineRunTemplate is the template for created PipelineRuns.
+type PipelineRunTemplate struct {
+	Metadata TemplateMetadata `json:"metadata,omitempty"`
+	// Spec is the Tekton PipelineRunSpec for created PipelineRuns.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Spec PipelineRunSpec `json:"spec,omitempty"`
+}
+
+// TemplateMetadata is the metadata that is applied to created PipelineRuns.
+type TemplateMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 type Param struct {
 	Name       string `json:"name"`
 	Expression string `json:"expression"`
+	// Type is the type of the param, if this is object, the expression must
+	// evaluate to a map, and the param is an object param, otherwise the type
+	// is determined by the result of the expression.
+	// +kubebuilder:validation:Enum=object
+	Type ParamType `json:"type,omitempty"`
 }
 
+// ParamType is the type of a param.
+type ParamType string
+
+// ObjectParamType is the type of params with object values.
+const ObjectParamType ParamType = "object"
+
 // AuthSecret references a secret for authenticating the request.
 type AuthSecret struct {
 	corev1.SecretReference `json:"secretRef,omitempty"`
@@ -53,6 +340,106 @@ type RepositoryStatus struct {
 	LastError          string `json:"lastError,omitempty"`
 	PollStatus         `json:"pollStatus,omitempty"`
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastTrigger is the value of the most recently handled TriggerAnnotation.
+	LastTrigger string `json:"lastTrigger,omitempty"`
+	// DeferredSHA is the SHA of a change that was detected during a blackout
+	// window, or while a previous run was active with the Queue concurrency
+	// policy, a run will be created when the window ends or the active runs
+	// complete.
+	DeferredSHA string `json:"deferredSHA,omitempty"`
+	// PendingTrigger is recorded before a run is created, and cleared once the
+	// run has been created, if creating the run fails, it's retried until the
+	// run is created.
+	PendingTrigger *PendingTrigger `json:"pendingTrigger,omitempty"`
+	// LastRun is the most recent run created for the Repository, this is
+	// updated as the run progresses.
+	LastRun *RunStatus `json:"lastRun,omitempty"`
+	// LastRuns are the most recent runs created for the Repository's
+	// Pipelines, these are updated as the runs progress.
+	LastRuns []RunStatus `json:"lastRuns,omitempty"`
+	// Conditions are the latest observations of the Repository's state.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// DryRun records the runs that would have been created for the most
+	// recent change, when the Repository is in dry-run mode.
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+}
+
+const (
+	// PipelineValidCondition records whether the params for the referenced
+	// Pipelines match the params that the Pipelines declare, runs are not
+	// created for Pipelines with invalid params.
+	PipelineValidCondition = "PipelineValid"
+
+	// PipelineValidReason is the reason when the params are valid.
+	PipelineValidReason = "Valid"
+	// InvalidParamsReason is the reason when the params don't match the
+	// Pipeline's declared params.
+	InvalidParamsReason = "InvalidParams"
+	// PipelineNotFoundReason is the reason when the referenced Pipeline
+	// doesn't exist.
+	PipelineNotFoundReason = "PipelineNotFound"
+
+	// ExpressionsValidCondition records whether the Repository's expressions
+	// compile, the expressions are compiled when the generation changes, and
+	// the Repository isn't polled if they fail to compile.
+	ExpressionsValidCondition = "ExpressionsValid"
+
+	// ExpressionsCompiledReason is the reason when all the expressions
+	// compile.
+	ExpressionsCompiledReason = "Compiled"
+	// CompileErrorReason is the reason when one or more expressions fail to
+	// compile.
+	CompileErrorReason = "CompileError"
+)
+
+// RunStatus is the outcome of a run created for a Repository.
+type RunStatus struct {
+	// Kind is the kind of the run, PipelineRun or TaskRun.
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// SHA is the commit that the run was created for.
+	SHA            string       `json:"sha,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Succeeded is the status of the run's Succeeded condition, this is
+	// Unknown while the run is active.
+	Succeeded corev1.ConditionStatus `json:"succeeded,omitempty"`
+	// Reason is the reason from the run's Succeeded condition.
+	Reason string `json:"reason,omitempty"`
+	// Pipeline is the name of the PipelineTarget that the run was created for.
+	Pipeline string `json:"pipeline,omitempty"`
+}
+
+// DryRunStatus is the outcome of rendering the runs for a change, without
+// creating them.
+type DryRunStatus struct {
+	// SHA is the commit that the runs were rendered for.
+	SHA string `json:"sha"`
+	// Runs are the rendered runs, this is empty if no runs would be created.
+	Runs []RenderedRun `json:"runs,omitempty"`
+}
+
+// RenderedRun is a run that would have been created for a change.
+type RenderedRun struct {
+	// Kind is the kind of the rendered resource, e.g. PipelineRun.
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	// Pipeline is the name of the PipelineTarget that the run was rendered
+	// for.
+	Pipeline string `json:"pipeline,omitempty"`
+	// Manifest is the rendered resource as YAML.
+	Manifest string `json:"manifest"`
+}
+
+// PendingTrigger is a change that a run is being created for.
+type PendingTrigger struct {
+	// SHA is the commit that the run is being created for.
+	SHA string `json:"sha"`
+	// ID is applied to the created run with the TriggerIDLabel, this is used
+	// to find a run that was created, but not recorded, so that only one run
+	// is created for each trigger.
+	ID string `json:"id"`
 }
 
 // PollStatus represents the last polled state of the repo.
@@ -72,6 +459,13 @@ func (p PollStatus) Equal(o PollStatus) bool {
 // Repository is the Schema for the repositories API
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=repositories,scope=Namespaced
+// +kubebuilder:printcolumn:name="URL",type="string",JSONPath=".spec.url",priority=1
+// +kubebuilder:printcolumn:name="Ref",type="string",JSONPath=".spec.ref"
+// +kubebuilder:printcolumn:name="SHA",type="string",JSONPath=".status.pollStatus.sha"
+// +kubebuilder:printcolumn:name="Last Run",type="string",JSONPath=".status.lastRun.name"
+// +kubebuilder:printcolumn:name="Succeeded",type="string",JSONPath=".status.lastRun.succeeded"
+// +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.lastRun.reason"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 type Repository struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -88,6 +482,22 @@ func (r *Repository) GetFrequency() time.Duration {
 	return time.Second * 30
 }
 
+// RunsTask returns true if the Repository executes a Task rather than a
+// Pipeline.
+func (r *Repository) RunsTask() bool {
+	return r.Spec.TaskSpec != nil || (r.Spec.Task != nil && r.Spec.Task.Name != "")
+}
+
+// RequestedTrigger returns the value of the TriggerAnnotation if it has not yet
+// been handled.
+func (r *Repository) RequestedTrigger() string {
+	token := r.GetAnnotations()[TriggerAnnotation]
+	if token == r.Status.LastTrigger {
+		return ""
+	}
+	return token
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // RepositoryList contains a list of Repository
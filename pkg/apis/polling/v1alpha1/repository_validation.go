@@ -0,0 +1,191 @@
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Validate returns an error if the Repository is not valid.
+func (r *Repository) Validate() error {
+	ref := r.Spec.Pipeline
+	hasPipeline := ref.Name != "" || ref.Resolver != "" || r.Spec.PipelineSpec != nil
+	if hasPipeline && r.RunsTask() {
+		return errors.New("only one of a pipeline or a task can be provided")
+	}
+	if r.Spec.TriggerTemplate != nil && (hasPipeline || r.RunsTask()) {
+		return errors.New("triggerTemplateRef can't be used with a pipeline or a task")
+	}
+	if len(r.Spec.Pipelines) > 0 && (hasPipeline || r.RunsTask() || r.Spec.TriggerTemplate != nil) {
+		return errors.New("pipelines can't be used with pipelineRef, pipelineSpec, a task or triggerTemplateRef")
+	}
+	if ref.Name != "" && r.Spec.PipelineSpec != nil {
+		return errors.New("only one of pipelineRef.name and pipelineSpec can be provided")
+	}
+	if ref.Resolver != "" && (ref.Name != "" || r.Spec.PipelineSpec != nil) {
+		return errors.New("pipelineRef.resolver can't be used with pipelineRef.name or pipelineSpec")
+	}
+	if ref.Bundle != "" && ref.Name == "" {
+		return errors.New("pipelineRef.bundle requires pipelineRef.name")
+	}
+	if len(ref.ResolverParams) > 0 && ref.Resolver == "" {
+		return errors.New("pipelineRef.resolverParams requires pipelineRef.resolver")
+	}
+	if ref.NamespaceSelector != nil && !hasPipeline {
+		return errors.New("pipelineRef.namespaceSelector can only be used with a pipeline")
+	}
+	if err := validateNamespaceSelector("pipelineRef", ref); err != nil {
+		return err
+	}
+	if r.Spec.Task != nil && r.Spec.Task.Name != "" && r.Spec.TaskSpec != nil {
+		return errors.New("only one of taskRef.name and taskSpec can be provided")
+	}
+	if r.Spec.TriggerTemplate != nil && r.Spec.TriggerTemplate.Name == "" {
+		return errors.New("triggerTemplateRef.name must be provided")
+	}
+	if len(r.Spec.Bindings) > 0 && r.Spec.TriggerTemplate == nil {
+		return errors.New("bindings requires triggerTemplateRef")
+	}
+	if !hasPipeline && !r.RunsTask() && r.Spec.TriggerTemplate == nil && len(r.Spec.Pipelines) == 0 {
+		return errors.New("one of pipelineRef.name, pipelineRef.resolver, pipelineSpec, pipelines, taskRef.name, taskSpec or triggerTemplateRef must be provided")
+	}
+	if err := validatePipelineTargets(r.Spec.Pipelines); err != nil {
+		return err
+	}
+	if err := validateBlackoutWindows(r.Spec.BlackoutWindows); err != nil {
+		return err
+	}
+	if r.Spec.TektonAPIVersion == "v1" && r.hasResources() {
+		return errors.New("pipeline resources can't be used with tektonAPIVersion v1")
+	}
+	if (r.RunsTask() || r.Spec.TriggerTemplate != nil) && r.Spec.PipelineRunTemplate != nil {
+		return errors.New("pipelineRunTemplate can only be used with a pipeline")
+	}
+	if r.Spec.TriggerTemplate != nil && r.Spec.ConcurrencyPolicy != "" && r.Spec.ConcurrencyPolicy != AllowConcurrent {
+		return errors.New("concurrencyPolicy can't be used with triggerTemplateRef")
+	}
+	if r.Spec.TriggerTemplate != nil && (r.Spec.SuccessfulRunsHistoryLimit != nil || r.Spec.FailedRunsHistoryLimit != nil) {
+		return errors.New("run history limits can't be used with triggerTemplateRef")
+	}
+	if ws := r.Spec.SourceWorkspace; ws != nil {
+		if r.Spec.TriggerTemplate != nil {
+			return errors.New("sourceWorkspace can't be used with triggerTemplateRef")
+		}
+		if size := ws.GetSize(); size.Sign() <= 0 {
+			return errors.New("sourceWorkspace.size must be greater than zero")
+		}
+	}
+	if r.Spec.ReportStatus != nil {
+		if r.Spec.TriggerTemplate != nil {
+			return errors.New("reportStatus can't be used with triggerTemplateRef")
+		}
+		if err := validateTemplate("reportStatus.targetURL", r.Spec.ReportStatus.TargetURL); err != nil {
+			return err
+		}
+	}
+	if checks := r.Spec.ReportChecks; checks != nil {
+		if r.Spec.TriggerTemplate != nil {
+			return errors.New("reportChecks can't be used with triggerTemplateRef")
+		}
+		if r.Spec.Type != GitHub {
+			return errors.New("reportChecks can only be used with GitHub repositories")
+		}
+		if checks.AppSecretRef.Name == "" {
+			return errors.New("reportChecks.appSecretRef.name must be provided")
+		}
+		if err := validateTemplate("reportChecks.detailsURL", checks.DetailsURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePipelineTargets(targets []PipelineTarget) error {
+	names := map[string]bool{}
+	for i, t := range targets {
+		if t.Name == "" {
+			return fmt.Errorf("pipelines[%d].name must be provided", i)
+		}
+		if errs := validation.IsValidLabelValue(t.Name); len(errs) > 0 {
+			return fmt.Errorf("pipelines[%d].name %q is not a valid label value", i, t.Name)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("pipelines[%d].name %q is not unique", i, t.Name)
+		}
+		names[t.Name] = true
+		ref := t.PipelineRef
+		if ref.Name == "" && ref.Resolver == "" {
+			return fmt.Errorf("one of pipelines[%d].pipelineRef.name or pipelines[%d].pipelineRef.resolver must be provided", i, i)
+		}
+		if ref.Name != "" && ref.Resolver != "" {
+			return fmt.Errorf("pipelines[%d].pipelineRef.resolver can't be used with pipelines[%d].pipelineRef.name", i, i)
+		}
+		if ref.Bundle != "" && ref.Name == "" {
+			return fmt.Errorf("pipelines[%d].pipelineRef.bundle requires pipelines[%d].pipelineRef.name", i, i)
+		}
+		if len(ref.ResolverParams) > 0 && ref.Resolver == "" {
+			return fmt.Errorf("pipelines[%d].pipelineRef.resolverParams requires pipelines[%d].pipelineRef.resolver", i, i)
+		}
+		if err := validateNamespaceSelector(fmt.Sprintf("pipelines[%d].pipelineRef", i), ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasResources returns true if any of the Repository's pipelines bind
+// PipelineResources, which aren't supported by the Tekton v1 API.
+func (r *Repository) hasResources() bool {
+	if len(r.Spec.Pipeline.Resources) > 0 {
+		return true
+	}
+	for _, t := range r.Spec.Pipelines {
+		if len(t.PipelineRef.Resources) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func validateBlackoutWindows(windows []BlackoutWindow) error {
+	for i, w := range windows {
+		if _, err := cron.ParseStandard(w.Start); err != nil {
+			return fmt.Errorf("failed to parse blackoutWindows[%d].start %q: %w", i, w.Start, err)
+		}
+		if w.Duration.Duration <= 0 {
+			return fmt.Errorf("blackoutWindows[%d].duration must be greater than zero", i)
+		}
+		if w.TimeZone == "" {
+			continue
+		}
+		if _, err := time.LoadLocation(w.TimeZone); err != nil {
+			return fmt.Errorf("failed to parse blackoutWindows[%d].timeZone %q: %w", i, w.TimeZone, err)
+		}
+	}
+	return nil
+}
+
+func validateNamespaceSelector(field string, ref PipelineRef) error {
+	if ref.NamespaceSelector == nil {
+		return nil
+	}
+	if ref.Namespace != "" {
+		return fmt.Errorf("only one of %s.namespace and %s.namespaceSelector can be provided", field, field)
+	}
+	if _, err := metav1.LabelSelectorAsSelector(ref.NamespaceSelector); err != nil {
+		return fmt.Errorf("%s.namespaceSelector is invalid: %w", field, err)
+	}
+	return nil
+}
+
+func validateTemplate(field, s string) error {
+	if _, err := template.New(field).Parse(s); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", field, err)
+	}
+	return nil
+}
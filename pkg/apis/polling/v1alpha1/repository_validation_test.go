@@ -0,0 +1,413 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRepositoryValidate(t *testing.T) {
+	validateTests := []struct {
+		name    string
+		spec    RepositorySpec
+		wantErr string
+	}{
+		{
+			"pipelineRef", RepositorySpec{Pipeline: PipelineRef{Name: "test-pipeline"}}, "",
+		},
+		{
+			"pipelineSpec", RepositorySpec{PipelineSpec: &PipelineSpec{}}, "",
+		},
+		{
+			"both pipelineRef and pipelineSpec",
+			RepositorySpec{
+				Pipeline:     PipelineRef{Name: "test-pipeline"},
+				PipelineSpec: &PipelineSpec{PipelineSpec: pipelinev1.PipelineSpec{Description: "testing"}},
+			},
+			"only one of pipelineRef.name and pipelineSpec can be provided",
+		},
+		{
+			"neither a pipeline or a task", RepositorySpec{}, "one of pipelineRef.name, pipelineRef.resolver, pipelineSpec, pipelines, taskRef.name, taskSpec or triggerTemplateRef must be provided",
+		},
+		{
+			"pipelines",
+			RepositorySpec{
+				Pipelines: []PipelineTarget{
+					{Name: "build", PipelineRef: PipelineRef{Name: "build-pipeline"}},
+					{Name: "docs", PipelineRef: PipelineRef{Resolver: "git"}, Filter: "commit.id != ''"},
+				},
+			},
+			"",
+		},
+		{
+			"pipelines with a pipelineRef",
+			RepositorySpec{
+				Pipeline:  PipelineRef{Name: "test-pipeline"},
+				Pipelines: []PipelineTarget{{Name: "build", PipelineRef: PipelineRef{Name: "build-pipeline"}}},
+			},
+			"pipelines can't be used with pipelineRef, pipelineSpec, a task or triggerTemplateRef",
+		},
+		{
+			"pipelines without a name",
+			RepositorySpec{Pipelines: []PipelineTarget{{PipelineRef: PipelineRef{Name: "build-pipeline"}}}},
+			"pipelines[0].name must be provided",
+		},
+		{
+			"pipelines with an invalid name",
+			RepositorySpec{Pipelines: []PipelineTarget{{Name: "build/test", PipelineRef: PipelineRef{Name: "build-pipeline"}}}},
+			`pipelines[0].name "build/test" is not a valid label value`,
+		},
+		{
+			"pipelines with duplicate names",
+			RepositorySpec{
+				Pipelines: []PipelineTarget{
+					{Name: "build", PipelineRef: PipelineRef{Name: "build-pipeline"}},
+					{Name: "build", PipelineRef: PipelineRef{Name: "scan-pipeline"}},
+				},
+			},
+			`pipelines[1].name "build" is not unique`,
+		},
+		{
+			"pipelines without a pipeline",
+			RepositorySpec{Pipelines: []PipelineTarget{{Name: "build"}}},
+			"one of pipelines[0].pipelineRef.name or pipelines[0].pipelineRef.resolver must be provided",
+		},
+		{
+			"namespaceSelector",
+			RepositorySpec{
+				Pipeline: PipelineRef{
+					Name:              "test-pipeline",
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+				},
+			},
+			"",
+		},
+		{
+			"namespaceSelector with a namespace",
+			RepositorySpec{
+				Pipeline: PipelineRef{
+					Name:              "test-pipeline",
+					Namespace:         "test-ns",
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+				},
+			},
+			"only one of pipelineRef.namespace and pipelineRef.namespaceSelector can be provided",
+		},
+		{
+			"invalid namespaceSelector",
+			RepositorySpec{
+				Pipeline: PipelineRef{
+					Name: "test-pipeline",
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: "Unknown"}},
+					},
+				},
+			},
+			`pipelineRef.namespaceSelector is invalid: "Unknown" is not a valid pod selector operator`,
+		},
+		{
+			"namespaceSelector with a task",
+			RepositorySpec{
+				Pipeline: PipelineRef{NamespaceSelector: &metav1.LabelSelector{}},
+				Task:     &TaskRef{Name: "test-task"},
+			},
+			"pipelineRef.namespaceSelector can only be used with a pipeline",
+		},
+		{
+			"pipelines with a namespaceSelector and a namespace",
+			RepositorySpec{
+				Pipelines: []PipelineTarget{
+					{
+						Name: "build",
+						PipelineRef: PipelineRef{
+							Name:              "build-pipeline",
+							Namespace:         "test-ns",
+							NamespaceSelector: &metav1.LabelSelector{},
+						},
+					},
+				},
+			},
+			"only one of pipelines[0].pipelineRef.namespace and pipelines[0].pipelineRef.namespaceSelector can be provided",
+		},
+		{
+			"sourceWorkspace",
+			RepositorySpec{Pipeline: PipelineRef{Name: "test-pipeline"}, SourceWorkspace: &SourceWorkspace{}},
+			"",
+		},
+		{
+			"sourceWorkspace with a triggerTemplateRef",
+			RepositorySpec{TriggerTemplate: &TriggerTemplateRef{Name: "test-template"}, SourceWorkspace: &SourceWorkspace{}},
+			"sourceWorkspace can't be used with triggerTemplateRef",
+		},
+		{
+			"sourceWorkspace with a zero size",
+			RepositorySpec{Pipeline: PipelineRef{Name: "test-pipeline"}, SourceWorkspace: &SourceWorkspace{Size: quantityPtr("0")}},
+			"sourceWorkspace.size must be greater than zero",
+		},
+		{
+			"bundle", RepositorySpec{Pipeline: PipelineRef{Name: "test-pipeline", Bundle: "example.com/pipelines:v1"}}, "",
+		},
+		{
+			"bundle without a name", RepositorySpec{Pipeline: PipelineRef{Bundle: "example.com/pipelines:v1"}}, "pipelineRef.bundle requires pipelineRef.name",
+		},
+		{
+			"resolver",
+			RepositorySpec{
+				Pipeline: PipelineRef{
+					Resolver:       "git",
+					ResolverParams: []ResolverParam{{Name: "pathInRepo", Value: "pipeline.yaml"}},
+				},
+			},
+			"",
+		},
+		{
+			"resolver with a name",
+			RepositorySpec{Pipeline: PipelineRef{Name: "test-pipeline", Resolver: "git"}},
+			"pipelineRef.resolver can't be used with pipelineRef.name or pipelineSpec",
+		},
+		{
+			"resolver with a pipelineSpec",
+			RepositorySpec{Pipeline: PipelineRef{Resolver: "git"}, PipelineSpec: &PipelineSpec{}},
+			"pipelineRef.resolver can't be used with pipelineRef.name or pipelineSpec",
+		},
+		{
+			"resolverParams without a resolver",
+			RepositorySpec{
+				Pipeline: PipelineRef{
+					Name:           "test-pipeline",
+					ResolverParams: []ResolverParam{{Name: "pathInRepo", Value: "pipeline.yaml"}},
+				},
+			},
+			"pipelineRef.resolverParams requires pipelineRef.resolver",
+		},
+		{
+			"taskRef", RepositorySpec{Task: &TaskRef{Name: "test-task"}}, "",
+		},
+		{
+			"taskSpec", RepositorySpec{TaskSpec: &TaskSpec{}}, "",
+		},
+		{
+			"both taskRef and taskSpec",
+			RepositorySpec{
+				Task:     &TaskRef{Name: "test-task"},
+				TaskSpec: &TaskSpec{},
+			},
+			"only one of taskRef.name and taskSpec can be provided",
+		},
+		{
+			"both a pipeline and a task",
+			RepositorySpec{
+				Pipeline: PipelineRef{Name: "test-pipeline"},
+				Task:     &TaskRef{Name: "test-task"},
+			},
+			"only one of a pipeline or a task can be provided",
+		},
+		{
+			"task with a pipelineRunTemplate",
+			RepositorySpec{
+				Task:                &TaskRef{Name: "test-task"},
+				PipelineRunTemplate: &PipelineRunTemplate{},
+			},
+			"pipelineRunTemplate can only be used with a pipeline",
+		},
+		{
+			"triggerTemplateRef",
+			RepositorySpec{
+				TriggerTemplate: &TriggerTemplateRef{Name: "test-template"},
+				Bindings:        []Param{{Name: "sha", Expression: "commit.id"}},
+			},
+			"",
+		},
+		{
+			"triggerTemplateRef without a name",
+			RepositorySpec{TriggerTemplate: &TriggerTemplateRef{}},
+			"triggerTemplateRef.name must be provided",
+		},
+		{
+			"triggerTemplateRef with a pipeline",
+			RepositorySpec{
+				Pipeline:        PipelineRef{Name: "test-pipeline"},
+				TriggerTemplate: &TriggerTemplateRef{Name: "test-template"},
+			},
+			"triggerTemplateRef can't be used with a pipeline or a task",
+		},
+		{
+			"bindings without a triggerTemplateRef",
+			RepositorySpec{
+				Pipeline: PipelineRef{Name: "test-pipeline"},
+				Bindings: []Param{{Name: "sha", Expression: "commit.id"}},
+			},
+			"bindings requires triggerTemplateRef",
+		},
+		{
+			"triggerTemplateRef with a pipelineRunTemplate",
+			RepositorySpec{
+				TriggerTemplate:     &TriggerTemplateRef{Name: "test-template"},
+				PipelineRunTemplate: &PipelineRunTemplate{},
+			},
+			"pipelineRunTemplate can only be used with a pipeline",
+		},
+		{
+			"triggerTemplateRef with a concurrencyPolicy",
+			RepositorySpec{
+				TriggerTemplate:   &TriggerTemplateRef{Name: "test-template"},
+				ConcurrencyPolicy: ReplaceConcurrent,
+			},
+			"concurrencyPolicy can't be used with triggerTemplateRef",
+		},
+		{
+			"triggerTemplateRef with a history limit",
+			RepositorySpec{
+				TriggerTemplate:        &TriggerTemplateRef{Name: "test-template"},
+				FailedRunsHistoryLimit: int32Ptr(1),
+			},
+			"run history limits can't be used with triggerTemplateRef",
+		},
+		{
+			"triggerTemplateRef with reportStatus",
+			RepositorySpec{
+				TriggerTemplate: &TriggerTemplateRef{Name: "test-template"},
+				ReportStatus:    &ReportStatus{},
+			},
+			"reportStatus can't be used with triggerTemplateRef",
+		},
+		{
+			"reportStatus with a target URL",
+			RepositorySpec{
+				Pipeline:     PipelineRef{Name: "test-pipeline"},
+				ReportStatus: &ReportStatus{TargetURL: "https://example.com/{{.Namespace}}/{{.Name}}"},
+			},
+			"",
+		},
+		{
+			"reportStatus with an invalid target URL",
+			RepositorySpec{
+				Pipeline:     PipelineRef{Name: "test-pipeline"},
+				ReportStatus: &ReportStatus{TargetURL: "https://example.com/{{.Name"},
+			},
+			"failed to parse reportStatus.targetURL: template: reportStatus.targetURL:1: unclosed action",
+		},
+		{
+			"reportChecks with a GitLab repository",
+			RepositorySpec{
+				Type:         GitLab,
+				Pipeline:     PipelineRef{Name: "test-pipeline"},
+				ReportChecks: &ReportChecks{AppSecretRef: corev1.SecretReference{Name: "test-app"}},
+			},
+			"reportChecks can only be used with GitHub repositories",
+		},
+		{
+			"reportChecks without a secret",
+			RepositorySpec{
+				Type:         GitHub,
+				Pipeline:     PipelineRef{Name: "test-pipeline"},
+				ReportChecks: &ReportChecks{},
+			},
+			"reportChecks.appSecretRef.name must be provided",
+		},
+		{
+			"reportChecks with an invalid details URL",
+			RepositorySpec{
+				Type:     GitHub,
+				Pipeline: PipelineRef{Name: "test-pipeline"},
+				ReportChecks: &ReportChecks{
+					AppSecretRef: corev1.SecretReference{Name: "test-app"},
+					DetailsURL:   "https://example.com/{{.Name",
+				},
+			},
+			"failed to parse reportChecks.detailsURL: template: reportChecks.detailsURL:1: unclosed action",
+		},
+		{
+			"resources with tektonAPIVersion v1",
+			RepositorySpec{
+				Pipeline: PipelineRef{
+					Name:      "test-pipeline",
+					Resources: []pipelinev1.PipelineResourceBinding{{Name: "source"}},
+				},
+				TektonAPIVersion: "v1",
+			},
+			"pipeline resources can't be used with tektonAPIVersion v1",
+		},
+		{
+			"resources with tektonAPIVersion v1beta1",
+			RepositorySpec{
+				Pipeline: PipelineRef{
+					Name:      "test-pipeline",
+					Resources: []pipelinev1.PipelineResourceBinding{{Name: "source"}},
+				},
+				TektonAPIVersion: "v1beta1",
+			},
+			"",
+		},
+		{
+			"blackout window",
+			RepositorySpec{
+				Pipeline: PipelineRef{Name: "test-pipeline"},
+				BlackoutWindows: []BlackoutWindow{
+					{Start: "0 18 * * 5", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Europe/London"},
+				},
+			},
+			"",
+		},
+		{
+			"blackout window with an invalid start",
+			RepositorySpec{
+				Pipeline:        PipelineRef{Name: "test-pipeline"},
+				BlackoutWindows: []BlackoutWindow{{Start: "0 18 * *", Duration: metav1.Duration{Duration: time.Hour}}},
+			},
+			`failed to parse blackoutWindows[0].start "0 18 * *": expected exactly 5 fields, found 4: [0 18 * *]`,
+		},
+		{
+			"blackout window without a duration",
+			RepositorySpec{
+				Pipeline:        PipelineRef{Name: "test-pipeline"},
+				BlackoutWindows: []BlackoutWindow{{Start: "0 18 * * 5"}},
+			},
+			"blackoutWindows[0].duration must be greater than zero",
+		},
+		{
+			"blackout window with an invalid time zone",
+			RepositorySpec{
+				Pipeline: PipelineRef{Name: "test-pipeline"},
+				BlackoutWindows: []BlackoutWindow{
+					{Start: "0 18 * * 5", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Unknown/Zone"},
+				},
+			},
+			`failed to parse blackoutWindows[0].timeZone "Unknown/Zone": unknown time zone Unknown/Zone`,
+		},
+		{
+			"reportChecks with a GitHub repository",
+			RepositorySpec{
+				Type:         GitHub,
+				Pipeline:     PipelineRef{Name: "test-pipeline"},
+				ReportChecks: &ReportChecks{AppSecretRef: corev1.SecretReference{Name: "test-app"}},
+			},
+			"",
+		},
+	}
+
+	for _, tt := range validateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Repository{Spec: tt.spec}).Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Validate() failed: %s", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("Validate() got %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func quantityPtr(s string) *resource.Quantity {
+	q := resource.MustParse(s)
+	return &q
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -0,0 +1,78 @@
+package blackout
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+)
+
+// Active returns true if the time falls within any of the windows, along with
+// the time that the latest ending window closes.
+func Active(windows []pollingv1.BlackoutWindow, t time.Time) (bool, time.Time, error) {
+	active := false
+	var end time.Time
+	for _, w := range windows {
+		windowEnd, err := activeUntil(w, t)
+		if err != nil {
+			return false, time.Time{}, err
+		}
+		if windowEnd.IsZero() {
+			continue
+		}
+		active = true
+		if windowEnd.After(end) {
+			end = windowEnd
+		}
+	}
+	return active, end, nil
+}
+
+// activeUntil returns the end of the window if t is within it, or the zero
+// time if it's not.
+func activeUntil(w pollingv1.BlackoutWindow, t time.Time) (time.Time, error) {
+	sched, err := cron.ParseStandard(w.Start)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse blackout window start %#v: %w", w.Start, err)
+	}
+	loc := time.UTC
+	if w.TimeZone != "" {
+		loc, err = time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse blackout window time zone %#v: %w", w.TimeZone, err)
+		}
+	}
+	// Every start after t - duration produces a window that contains t, if
+	// the windows overlap, the latest start produces the latest end.
+	start := latestStart(sched, t.In(loc).Add(-w.Duration.Duration), t.In(loc))
+	if start.IsZero() {
+		return time.Time{}, nil
+	}
+	return start.Add(w.Duration.Duration), nil
+}
+
+// latestStart returns the latest time that the schedule activates after from,
+// and no later than t, or the zero time if it doesn't activate.
+//
+// Schedules activate on whole seconds, so this searches for the latest second
+// that the schedule next activates no later than t, rather than stepping
+// through each activation, which could be every minute for a long window.
+func latestStart(sched cron.Schedule, from, t time.Time) time.Time {
+	if sched.Next(from).After(t) {
+		return time.Time{}
+	}
+	// The schedule next activates no later than t after lo, and later than t
+	// after hi.
+	lo, hi := from, t
+	for hi.Sub(lo) > time.Second {
+		mid := lo.Add(hi.Sub(lo) / 2)
+		if sched.Next(mid).After(t) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return sched.Next(lo)
+}
@@ -0,0 +1,152 @@
+package blackout
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+)
+
+// Fridays at 18:00 for 2 days and 14 hours (until Monday at 08:00).
+var weekend = pollingv1.BlackoutWindow{
+	Start:    "0 18 * * 5",
+	Duration: metav1.Duration{Duration: time.Hour * 62},
+}
+
+func TestActive(t *testing.T) {
+	london := weekend
+	london.TimeZone = "Europe/London"
+
+	activeTests := []struct {
+		name       string
+		windows    []pollingv1.BlackoutWindow
+		t          time.Time
+		wantActive bool
+		wantEnd    time.Time
+	}{
+		{"no windows", nil, parseTime(t, "2020-10-09T19:00:00Z"), false, time.Time{}},
+		{"before the window", []pollingv1.BlackoutWindow{weekend}, parseTime(t, "2020-10-09T17:59:59Z"), false, time.Time{}},
+		{"start of the window", []pollingv1.BlackoutWindow{weekend}, parseTime(t, "2020-10-09T18:00:00Z"), true, parseTime(t, "2020-10-12T08:00:00Z")},
+		{"within the window", []pollingv1.BlackoutWindow{weekend}, parseTime(t, "2020-10-11T12:00:00Z"), true, parseTime(t, "2020-10-12T08:00:00Z")},
+		{"end of the window", []pollingv1.BlackoutWindow{weekend}, parseTime(t, "2020-10-12T08:00:00Z"), false, time.Time{}},
+		{"after the window", []pollingv1.BlackoutWindow{weekend}, parseTime(t, "2020-10-13T09:00:00Z"), false, time.Time{}},
+		{"in a time zone", []pollingv1.BlackoutWindow{london}, parseTime(t, "2020-10-09T17:30:00Z"), true, parseTime(t, "2020-10-12T07:00:00Z")},
+		{
+			"overlapping windows",
+			[]pollingv1.BlackoutWindow{
+				weekend,
+				{Start: "0 0 * * 1", Duration: metav1.Duration{Duration: time.Hour * 12}},
+			},
+			parseTime(t, "2020-10-12T01:00:00Z"), true, parseTime(t, "2020-10-12T12:00:00Z"),
+		},
+		{
+			"window longer than the schedule",
+			[]pollingv1.BlackoutWindow{
+				{Start: "0 * * * *", Duration: metav1.Duration{Duration: time.Minute * 90}},
+			},
+			parseTime(t, "2020-10-12T01:10:00Z"), true, parseTime(t, "2020-10-12T02:30:00Z"),
+		},
+		{
+			"every minute for a year",
+			[]pollingv1.BlackoutWindow{
+				{Start: "* * * * *", Duration: metav1.Duration{Duration: time.Hour * 24 * 365}},
+			},
+			parseTime(t, "2020-10-12T01:10:30Z"), true, parseTime(t, "2021-10-12T01:10:00Z"),
+		},
+	}
+
+	for _, tt := range activeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, end, err := Active(tt.windows, tt.t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if active != tt.wantActive {
+				t.Errorf("Active() got %v, want %v", active, tt.wantActive)
+			}
+			if !end.Equal(tt.wantEnd) {
+				t.Errorf("Active() end got %s, want %s", end, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestLatestStart(t *testing.T) {
+	sched := &countingSchedule{Schedule: mustParse(t, "* * * * *")}
+	now := parseTime(t, "2020-10-12T01:10:30Z")
+
+	start := latestStart(sched, now.Add(-time.Hour*24*365), now)
+
+	if want := parseTime(t, "2020-10-12T01:10:00Z"); !start.Equal(want) {
+		t.Fatalf("latestStart() got %s, want %s", start, want)
+	}
+	// The search takes a call for each halving of the duration, rather than
+	// one for each of the 525,600 starts.
+	if sched.calls > 30 {
+		t.Fatalf("latestStart() called Next %d times", sched.calls)
+	}
+}
+
+type countingSchedule struct {
+	cron.Schedule
+	calls int
+}
+
+func (s *countingSchedule) Next(t time.Time) time.Time {
+	s.calls++
+	return s.Schedule.Next(t)
+}
+
+func mustParse(t *testing.T, spec string) cron.Schedule {
+	t.Helper()
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sched
+}
+
+func TestActiveWithInvalidWindows(t *testing.T) {
+	invalidTests := []struct {
+		window  pollingv1.BlackoutWindow
+		wantErr string
+	}{
+		{pollingv1.BlackoutWindow{Start: "0 18 * *"}, `failed to parse blackout window start "0 18 \* \*"`},
+		{pollingv1.BlackoutWindow{Start: "0 18 * * 5", TimeZone: "Unknown/Zone"}, `failed to parse blackout window time zone "Unknown/Zone"`},
+	}
+
+	for _, tt := range invalidTests {
+		_, _, err := Active([]pollingv1.BlackoutWindow{tt.window}, time.Now())
+		if !matchError(t, tt.wantErr, err) {
+			t.Errorf("Active() got error %v, want %s", err, tt.wantErr)
+		}
+	}
+}
+
+func parseTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func matchError(t *testing.T, s string, e error) bool {
+	t.Helper()
+	if s == "" && e == nil {
+		return true
+	}
+	if s != "" && e == nil {
+		return false
+	}
+	match, err := regexp.MatchString(s, e.Error())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return match
+}
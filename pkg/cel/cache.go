@@ -0,0 +1,138 @@
+package cel
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+)
+
+// maxCachedPrograms limits the number of compiled programs that are kept, when
+// the cache is full, an arbitrary program is dropped to make space.
+const maxCachedPrograms = 4096
+
+// envVersion identifies the variables and functions that are declared in the
+// environment, this must be changed when the declarations change, so that
+// programs compiled with different declarations aren't mixed up.
+const envVersion = "v2"
+
+var (
+	defaultProgramsMu sync.Mutex
+	defaultPrograms   *programCache
+	// makeEnv creates the environment for the shared programs.
+	makeEnv = makeCelEnv
+)
+
+// sharedPrograms returns the cache of programs compiled in the environment
+// that all Contexts use.
+//
+// If the environment can't be created, the error is returned, and it's
+// created again on the next call.
+func sharedPrograms() (*programCache, error) {
+	defaultProgramsMu.Lock()
+	defer defaultProgramsMu.Unlock()
+	if defaultPrograms != nil {
+		return defaultPrograms, nil
+	}
+	env, err := makeEnv()
+	if err != nil {
+		return nil, err
+	}
+	defaultPrograms = newProgramCache(env, envVersion)
+	return defaultPrograms, nil
+}
+
+// compiled is the outcome of compiling an expression, expressions that fail
+// to compile are cached with the error.
+type compiled struct {
+	program    cel.Program
+	outputType *cel.Type
+	err        error
+}
+
+// programKey identifies a compiled program by the environment version and
+// the expression.
+type programKey struct {
+	version string
+	expr    string
+}
+
+// programCache compiles expressions in an environment, each expression is
+// parsed, type-checked and planned once, and the program is reused for every
+// evaluation, programs are safe for concurrent use.
+type programCache struct {
+	env      *cel.Env
+	version  string
+	mu       sync.RWMutex
+	programs map[programKey]compiled
+}
+
+func newProgramCache(env *cel.Env, version string) *programCache {
+	return &programCache{env: env, version: version, programs: make(map[programKey]compiled)}
+}
+
+// get returns the compiled expression, compiling it if it's not cached.
+func (c *programCache) get(expr string) compiled {
+	key := programKey{version: c.version, expr: expr}
+	c.mu.RLock()
+	p, ok := c.programs[key]
+	c.mu.RUnlock()
+	if ok {
+		return p
+	}
+	p = c.compile(expr)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.programs) >= maxCachedPrograms {
+		for k := range c.programs {
+			delete(c.programs, k)
+			break
+		}
+	}
+	c.programs[key] = p
+	return p
+}
+
+func (c *programCache) compile(expr string) compiled {
+	parsed, issues := c.env.Parse(expr)
+	if issues != nil && issues.Err() != nil {
+		return compiled{err: issues.Err()}
+	}
+	checked, issues := c.env.Check(parsed)
+	if issues != nil && issues.Err() != nil {
+		return compiled{err: issues.Err()}
+	}
+	prg, err := c.env.Program(checked)
+	if err != nil {
+		return compiled{err: err}
+	}
+	return compiled{program: prg, outputType: checked.OutputType()}
+}
+
+// Compile parses and type-checks the expression, and caches the program for
+// evaluation.
+func Compile(expr string) error {
+	programs, err := sharedPrograms()
+	if err != nil {
+		return err
+	}
+	return programs.get(expr).err
+}
+
+// CompileFilter compiles the expression, which must evaluate to a bool, the
+// result can only be checked when the expression's type is known, e.g.
+// comparisons, and not values from the commit.
+func CompileFilter(expr string) error {
+	programs, err := sharedPrograms()
+	if err != nil {
+		return err
+	}
+	p := programs.get(expr)
+	if p.err != nil {
+		return p.err
+	}
+	if t := p.outputType; t != cel.DynType && !t.IsAssignableType(cel.BoolType) {
+		return fmt.Errorf("expression must evaluate to a bool, not %s", t)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package cel
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+)
+
+func TestProgramCacheCompilesOnce(t *testing.T) {
+	env, err := makeCelEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	programs := newProgramCache(env, envVersion)
+
+	first := programs.get("commit.id")
+	second := programs.get("commit.id")
+
+	if first.err != nil {
+		t.Fatal(first.err)
+	}
+	if first.program != second.program {
+		t.Fatal("program was compiled twice")
+	}
+	if l := len(programs.programs); l != 1 {
+		t.Fatalf("got %d cached programs, want 1", l)
+	}
+}
+
+func TestProgramCacheCachesErrors(t *testing.T) {
+	env, err := makeCelEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	programs := newProgramCache(env, envVersion)
+
+	p := programs.get("body.value = 'testing'")
+
+	if p.err == nil || !matchError(t, "Syntax error", p.err) {
+		t.Fatalf("got error %v, want a syntax error", p.err)
+	}
+	if _, ok := programs.programs[programKey{version: envVersion, expr: "body.value = 'testing'"}]; !ok {
+		t.Fatal("the failed compilation was not cached")
+	}
+}
+
+func TestProgramCacheIsBounded(t *testing.T) {
+	env, err := makeCelEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	programs := newProgramCache(env, envVersion)
+
+	for i := 0; i < maxCachedPrograms+10; i++ {
+		programs.get("'" + string(rune('a'+i%26)) + "' + '" + string(rune(i)) + "'")
+	}
+
+	if l := len(programs.programs); l > maxCachedPrograms {
+		t.Fatalf("got %d cached programs, want at most %d", l, maxCachedPrograms)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	if err := Compile("head.sha.shortSHA(7)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Compile("unknown.value"); err == nil || !matchError(t, "undeclared reference to 'unknown'", err) {
+		t.Fatalf("Compile() got %v", err)
+	}
+}
+
+func TestCompileFilter(t *testing.T) {
+	filterTests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"ref == 'main'", ""},
+		{"commit.draft", ""},
+		{"ref", "expression must evaluate to a bool, not string"},
+		{"ref ==", "Syntax error"},
+	}
+
+	for _, tt := range filterTests {
+		t.Run(tt.expr, func(t *testing.T) {
+			err := CompileFilter(tt.expr)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !matchError(t, tt.wantErr, err) {
+				t.Fatalf("CompileFilter() got %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSharedProgramsRetriesFailedEnv(t *testing.T) {
+	defaultProgramsMu.Lock()
+	saved := defaultPrograms
+	defaultPrograms = nil
+	defaultProgramsMu.Unlock()
+	t.Cleanup(func() {
+		makeEnv = makeCelEnv
+		defaultProgramsMu.Lock()
+		defaultPrograms = saved
+		defaultProgramsMu.Unlock()
+	})
+
+	makeEnv = func() (*cel.Env, error) {
+		return nil, errors.New("failed to create env")
+	}
+	if _, err := sharedPrograms(); err == nil {
+		t.Fatal("expected an error creating the env")
+	}
+
+	makeEnv = makeCelEnv
+	programs, err := sharedPrograms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if programs == nil {
+		t.Fatal("no programs were returned")
+	}
+}
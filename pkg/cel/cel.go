@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
+	"time"
 
 	structpb "github.com/golang/protobuf/ptypes/struct"
 	"github.com/google/cel-go/cel"
@@ -12,37 +14,69 @@ import (
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/cel-go/common/types/traits"
 	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
-)
 
-var (
-	listType = reflect.TypeOf(&structpb.ListValue{})
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/git"
 )
 
+var jsonValueType = reflect.TypeOf(&structpb.Value{})
+
 // Context makes it easy to execute CEL expressions on JSON body.
 type Context struct {
-	env  *cel.Env
-	Data map[string]interface{}
+	programs *programCache
+	Data     map[string]interface{}
+}
+
+// Input is the data that expressions are evaluated against.
+type Input struct {
+	// RepoURL is the URL of the polled repository.
+	RepoURL string
+	// Commit is the commit as returned by the provider's API.
+	Commit interface{}
+	// Head is the commit normalised across providers.
+	Head git.CommitInfo
+	// Ref is the polled branch or tag.
+	Ref string
+	// PreviousSHA is the SHA recorded by the previous poll, this is empty
+	// for the first poll.
+	PreviousSHA string
+	// Provider is the type of the repository, e.g. github or gitlab.
+	Provider string
+	// PollTime is when the repository was polled.
+	PollTime time.Time
+	// Repository is the polled Repository.
+	Repository Repository
+}
+
+// Repository identifies the Repository that was polled.
+type Repository struct {
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
 }
 
 // New creates and returns a Context for evaluating expressions.
-func New(repoURL string, commit interface{}) (*Context, error) {
-	env, err := makeCelEnv()
+//
+// The expressions are compiled once, and the programs are shared by all
+// Contexts.
+func New(in Input) (*Context, error) {
+	programs, err := sharedPrograms()
 	if err != nil {
 		return nil, err
 	}
-	ctx, err := makeEvalContext(repoURL, commit)
+	ctx, err := makeEvalContext(in)
 	if err != nil {
 		return nil, err
 	}
 	return &Context{
-		env:  env,
-		Data: ctx,
+		programs: programs,
+		Data:     ctx,
 	}, nil
 }
 
 // Evaluate evaluates the provided expression and returns the result.
 func (c *Context) Evaluate(expr string) (ref.Val, error) {
-	return evaluate(expr, c.env, c.Data)
+	return evaluate(expr, c.programs, c.Data)
 }
 
 // EvaluateToParamValue evaluates the provided expression, and converts it to a
@@ -55,39 +89,71 @@ func (c *Context) EvaluateToParamValue(expr string) (*pipelinev1beta1.ArrayOrStr
 	return valToParam(res)
 }
 
-func evaluate(expr string, env *cel.Env, data map[string]interface{}) (ref.Val, error) {
-	parsed, issues := env.Parse(expr)
-	if issues != nil && issues.Err() != nil {
-		return nil, issues.Err()
-	}
-
-	checked, issues := env.Check(parsed)
-	if issues != nil && issues.Err() != nil {
-		return nil, issues.Err()
-	}
-
-	prg, err := env.Program(checked)
-	if err != nil {
-		return nil, err
+func evaluate(expr string, programs *programCache, data map[string]interface{}) (ref.Val, error) {
+	p := programs.get(expr)
+	if p.err != nil {
+		return nil, p.err
 	}
-
-	out, _, err := prg.Eval(data)
+	out, _, err := p.program.Eval(data)
 	return out, err
 }
 
 func makeCelEnv() (*cel.Env, error) {
-	return cel.NewEnv(
+	opts := append(extensions(),
 		cel.Declarations(
 			decls.NewIdent("commit", decls.Dyn, nil),
-			decls.NewIdent("repoURL", decls.String, nil)))
+			decls.NewIdent("repoURL", decls.String, nil),
+			decls.NewIdent("ref", decls.String, nil),
+			decls.NewIdent("previousSHA", decls.String, nil),
+			decls.NewIdent("provider", decls.String, nil),
+			decls.NewIdent("pollTime", decls.String, nil),
+			decls.NewIdent("repository", decls.NewMapType(decls.String, decls.Dyn), nil),
+			decls.NewIdent("head", decls.NewMapType(decls.String, decls.String), nil)))
+	return cel.NewEnv(opts...)
 }
 
-func makeEvalContext(repoURL string, commit interface{}) (map[string]interface{}, error) {
-	m, err := commitToMap(commit)
+func makeEvalContext(in Input) (map[string]interface{}, error) {
+	m, err := commitToMap(in.Commit)
 	if err != nil {
 		return nil, err
 	}
-	return map[string]interface{}{"commit": m, "repoURL": repoURL}, nil
+	return map[string]interface{}{
+		"commit":      m,
+		"repoURL":     in.RepoURL,
+		"ref":         in.Ref,
+		"previousSHA": in.PreviousSHA,
+		"provider":    in.Provider,
+		"pollTime":    formatTime(in.PollTime),
+		"repository": map[string]interface{}{
+			"name":        in.Repository.Name,
+			"namespace":   in.Repository.Namespace,
+			"labels":      stringMap(in.Repository.Labels),
+			"annotations": stringMap(in.Repository.Annotations),
+		},
+		"head": map[string]string{
+			"sha":       in.Head.SHA,
+			"author":    in.Head.Author,
+			"message":   in.Head.Message,
+			"timestamp": formatTime(in.Head.Timestamp),
+		},
+	}, nil
+}
+
+// formatTime returns the time in UTC as an RFC3339 string, or an empty string
+// for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func stringMap(m map[string]string) map[string]interface{} {
+	converted := map[string]interface{}{}
+	for k, v := range m {
+		converted[k] = v
+	}
+	return converted
 }
 
 func commitToMap(v interface{}) (map[string]interface{}, error) {
@@ -100,24 +166,95 @@ func commitToMap(v interface{}) (map[string]interface{}, error) {
 	return m, err
 }
 
-// TODO: This should probably stringify other ref.Types
+// EvaluateToObject evaluates the provided expression, which must evaluate to
+// a map, and converts it to the properties of an object param.
+//
+// Values that aren't strings are converted as they are for string params.
+func (c *Context) EvaluateToObject(expr string) (map[string]string, error) {
+	res, err := c.Evaluate(expr)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := res.(traits.Mapper)
+	if !ok {
+		return nil, fmt.Errorf("expression %q must evaluate to a map, got %s", expr, res.Type().TypeName())
+	}
+	object := map[string]string{}
+	it := m.Iterator()
+	for it.HasNext() == types.True {
+		k := it.Next()
+		key, err := valToString(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := valToString(m.Get(k))
+		if err != nil {
+			return nil, err
+		}
+		object[key] = value
+	}
+	return object, nil
+}
+
+// valToParam converts the result of an expression to a param value, lists
+// are converted to array params, and all other values to string params.
 func valToParam(v ref.Val) (*pipelinev1beta1.ArrayOrString, error) {
-	switch val := v.(type) {
-	case traits.Lister:
-		items := []string{}
-		it := val.Iterator()
-		for it.HasNext() == types.True {
-			str, err := it.Next().ConvertToNative(reflect.TypeOf(""))
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert expression to a string: %w", err)
-			}
-			items = append(items, str.(string))
+	lister, ok := v.(traits.Lister)
+	if !ok {
+		s, err := valToString(v)
+		if err != nil {
+			return nil, err
+		}
+		return pipelinev1beta1.NewArrayOrString(s), nil
+	}
+	items := []string{}
+	it := lister.Iterator()
+	for it.HasNext() == types.True {
+		s, err := valToString(it.Next())
+		if err != nil {
+			return nil, err
 		}
-		return pipelinev1beta1.NewArrayOrString(items[0], items[1:]...), nil
+		items = append(items, s)
+	}
+	return &pipelinev1beta1.ArrayOrString{Type: pipelinev1beta1.ParamTypeArray, ArrayVal: items}, nil
+}
+
+// valToString converts a value to a string, lists and maps are JSON encoded,
+// timestamps are formatted as RFC3339, and null is an empty string.
+func valToString(v ref.Val) (string, error) {
+	switch val := v.(type) {
 	case types.String:
-		return pipelinev1beta1.NewArrayOrString(val.Value().(string)), nil
+		return string(val), nil
+	case types.Bool:
+		return strconv.FormatBool(bool(val)), nil
+	case types.Int:
+		return strconv.FormatInt(int64(val), 10), nil
+	case types.Uint:
+		return strconv.FormatUint(uint64(val), 10), nil
 	case types.Double:
-		return pipelinev1beta1.NewArrayOrString(fmt.Sprintf("%g", val.Value().(float64))), nil
+		return strconv.FormatFloat(float64(val), 'f', -1, 64), nil
+	case types.Bytes:
+		return string(val), nil
+	case types.Null:
+		return "", nil
+	case types.Timestamp:
+		return val.Time.Format(time.RFC3339Nano), nil
+	case types.Duration:
+		return val.Duration.String(), nil
+	case traits.Lister, traits.Mapper:
+		return valToJSON(v)
+	}
+	return "", fmt.Errorf("unknown result type %s, expression must evaluate to a string, number, bool, timestamp, duration, list or map", v.Type().TypeName())
+}
+
+func valToJSON(v ref.Val) (string, error) {
+	native, err := v.ConvertToNative(jsonValueType)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert expression to JSON: %w", err)
+	}
+	b, err := json.Marshal(native.(*structpb.Value).AsInterface())
+	if err != nil {
+		return "", fmt.Errorf("failed to convert expression to JSON: %w", err)
 	}
-	return nil, fmt.Errorf("unknown result type %T, expression must evaluate to a string", v)
+	return string(b), nil
 }
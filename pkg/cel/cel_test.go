@@ -1,17 +1,23 @@
 package cel
 
 import (
+	"encoding/json"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	"github.com/google/go-cmp/cmp"
 	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/git"
 )
 
 const testRepoURL = "https://example.com/example/example.git"
 
+var testPollTime = time.Date(2020, time.October, 7, 12, 0, 0, 0, time.UTC)
+
 func TestExpressionEvaluation(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -43,12 +49,12 @@ func TestExpressionEvaluation(t *testing.T) {
 				rt.Errorf("failed to make env: %s", err)
 				return
 			}
-			ectx, err := makeEvalContext(testRepoURL, tt.fixture)
+			ectx, err := makeEvalContext(Input{RepoURL: testRepoURL, Commit: tt.fixture})
 			if err != nil {
 				rt.Errorf("failed to make eval context %s", err)
 				return
 			}
-			got, err := evaluate(tt.expr, env, ectx)
+			got, err := evaluate(tt.expr, newProgramCache(env, envVersion), ectx)
 			if err != nil {
 				rt.Errorf("evaluate() got an error %s", err)
 				return
@@ -66,6 +72,63 @@ func TestExpressionEvaluation(t *testing.T) {
 	}
 }
 
+func TestExpressionEvaluationWithInput(t *testing.T) {
+	in := Input{
+		RepoURL:     testRepoURL,
+		Commit:      map[string]interface{}{"id": "testing"},
+		Ref:         "main",
+		PreviousSHA: "6104942438c14ec7bd21c6cd5bd995272b3faff6",
+		Provider:    "gitlab",
+		PollTime:    testPollTime,
+		Repository: Repository{
+			Name:        "test-repository",
+			Namespace:   "test-ns",
+			Labels:      map[string]string{"app": "example"},
+			Annotations: map[string]string{"example.com/team": "builders"},
+		},
+		Head: git.CommitInfo{
+			SHA:       "ed899a2f4b50b4370feeea94676502b42383c746",
+			Author:    "Example User",
+			Message:   "Replace sanitize with escape once",
+			Timestamp: time.Date(2012, time.September, 20, 11, 50, 22, 0, time.FixedZone("EEST", 3*60*60)),
+		},
+	}
+	tests := []struct {
+		expr string
+		want ref.Val
+	}{
+		{"ref", types.String("main")},
+		{"previousSHA", types.String("6104942438c14ec7bd21c6cd5bd995272b3faff6")},
+		{"provider", types.String("gitlab")},
+		{"pollTime", types.String("2020-10-07T12:00:00Z")},
+		{"repository.name", types.String("test-repository")},
+		{"repository.namespace", types.String("test-ns")},
+		{"repository.labels.app", types.String("example")},
+		{"repository.annotations['example.com/team']", types.String("builders")},
+		{"'team' in repository.labels", types.False},
+		{"head.sha", types.String("ed899a2f4b50b4370feeea94676502b42383c746")},
+		{"head.author", types.String("Example User")},
+		{"head.message", types.String("Replace sanitize with escape once")},
+		{"head.timestamp", types.String("2012-09-20T08:50:22Z")},
+		{"head.sha != previousSHA", types.True},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(rt *testing.T) {
+			ctx, err := New(in)
+			if err != nil {
+				rt.Fatal(err)
+			}
+			got, err := ctx.Evaluate(tt.expr)
+			if err != nil {
+				rt.Fatalf("Evaluate() got an error %s", err)
+			}
+			if !got.Equal(tt.want).(types.Bool) {
+				rt.Errorf("Evaluate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestExpressionEvaluation_Error(t *testing.T) {
 	tests := []struct {
 		name string
@@ -90,12 +153,12 @@ func TestExpressionEvaluation_Error(t *testing.T) {
 				rt.Errorf("failed to make env: %s", err)
 				return
 			}
-			ectx, err := makeEvalContext(testRepoURL, map[string]string{"this": "tests"})
+			ectx, err := makeEvalContext(Input{RepoURL: testRepoURL, Commit: map[string]string{"this": "tests"}})
 			if err != nil {
 				rt.Errorf("failed to make eval context %s", err)
 				return
 			}
-			_, err = evaluate(tt.expr, env, ectx)
+			_, err = evaluate(tt.expr, newProgramCache(env, envVersion), ectx)
 			if !matchError(t, tt.want, err) {
 				rt.Errorf("evaluate() got %s, wanted %s", err, tt.want)
 			}
@@ -114,7 +177,7 @@ func TestContextEvaluateToParamValue(t *testing.T) {
 		"head": "test-value",
 	}
 
-	ctx, err := New(testRepoURL, v)
+	ctx, err := New(Input{RepoURL: testRepoURL, Commit: v})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -132,6 +195,119 @@ func TestContextEvaluateToParamValue(t *testing.T) {
 	}
 }
 
+func TestContextEvaluateToParamValueTypes(t *testing.T) {
+	tests := []struct {
+		expr string
+		want *pipelinev1beta1.ArrayOrString
+	}{
+		{"'testing'", pipelinev1beta1.NewArrayOrString("testing")},
+		{"true", pipelinev1beta1.NewArrayOrString("true")},
+		{"1 > 2", pipelinev1beta1.NewArrayOrString("false")},
+		{"42", pipelinev1beta1.NewArrayOrString("42")},
+		{"-42", pipelinev1beta1.NewArrayOrString("-42")},
+		{"42u", pipelinev1beta1.NewArrayOrString("42")},
+		{"1.5", pipelinev1beta1.NewArrayOrString("1.5")},
+		{"1234567.0", pipelinev1beta1.NewArrayOrString("1234567")},
+		{"0.000001", pipelinev1beta1.NewArrayOrString("0.000001")},
+		{"b'bytes'", pipelinev1beta1.NewArrayOrString("bytes")},
+		{"null", pipelinev1beta1.NewArrayOrString("")},
+		{"timestamp('2020-10-07T12:00:00Z')", pipelinev1beta1.NewArrayOrString("2020-10-07T12:00:00Z")},
+		{"duration('90m')", pipelinev1beta1.NewArrayOrString("1h30m0s")},
+		{"{'b': 1, 'a': ['x', true]}", pipelinev1beta1.NewArrayOrString(`{"a":["x",true],"b":1}`)},
+		{"commit", pipelinev1beta1.NewArrayOrString(`{"id":"testing"}`)},
+		{"['a', 'b']", pipelinev1beta1.NewArrayOrString("a", "b")},
+		{"[1, true, 'c']", pipelinev1beta1.NewArrayOrString("1", "true", "c")},
+		{"[{'a': 'b'}, ['c']]", pipelinev1beta1.NewArrayOrString(`{"a":"b"}`, `["c"]`)},
+		{"[]", &pipelinev1beta1.ArrayOrString{Type: pipelinev1beta1.ParamTypeArray, ArrayVal: []string{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(rt *testing.T) {
+			ctx, err := New(Input{RepoURL: testRepoURL, Commit: map[string]interface{}{"id": "testing"}})
+			if err != nil {
+				rt.Fatal(err)
+			}
+			got, err := ctx.EvaluateToParamValue(tt.expr)
+			if err != nil {
+				rt.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				rt.Fatalf("EvaluateToParamValue() failed:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContextEvaluateToParamValueWithJSONNumber(t *testing.T) {
+	// Numbers in JSON hook bodies and API responses are decoded as float64.
+	var commit map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"project_id": 1234567}`), &commit); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := New(Input{RepoURL: testRepoURL, Commit: commit})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ctx.EvaluateToParamValue("commit.project_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(pipelinev1beta1.NewArrayOrString("1234567"), got); diff != "" {
+		t.Fatalf("EvaluateToParamValue() failed:\n%s", diff)
+	}
+}
+
+func TestContextEvaluateToParamValue_Error(t *testing.T) {
+	ctx, err := New(Input{RepoURL: testRepoURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ctx.EvaluateToParamValue("string")
+	if err == nil || !matchError(t, "unknown result type type", err) {
+		t.Fatalf("EvaluateToParamValue() got %v", err)
+	}
+}
+
+func TestContextEvaluateToObject(t *testing.T) {
+	tests := []struct {
+		expr string
+		want map[string]string
+	}{
+		{"{'url': repoURL, 'revision': commit.id}", map[string]string{"url": testRepoURL, "revision": "testing"}},
+		{"{'count': 2, 'ok': true, 'tags': ['a']}", map[string]string{"count": "2", "ok": "true", "tags": `["a"]`}},
+		{"{}", map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(rt *testing.T) {
+			ctx, err := New(Input{RepoURL: testRepoURL, Commit: map[string]interface{}{"id": "testing"}})
+			if err != nil {
+				rt.Fatal(err)
+			}
+			got, err := ctx.EvaluateToObject(tt.expr)
+			if err != nil {
+				rt.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				rt.Fatalf("EvaluateToObject() failed:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContextEvaluateToObject_Error(t *testing.T) {
+	ctx, err := New(Input{RepoURL: testRepoURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ctx.EvaluateToObject("repoURL")
+	if err == nil || !matchError(t, `expression "repoURL" must evaluate to a map, got string`, err) {
+		t.Fatalf("EvaluateToObject() got %v", err)
+	}
+}
+
 // TODO move this and share via a specific test package.
 func matchError(t *testing.T, s string, e error) bool {
 	t.Helper()
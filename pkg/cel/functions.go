@@ -0,0 +1,170 @@
+package cel
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+	"golang.org/x/mod/semver"
+)
+
+const defaultShortSHALength = 7
+
+// extensions returns the functions that are available to expressions in
+// addition to the standard CEL functions.
+//
+// The string functions, e.g. split, lowerAscii, replace and substring, and
+// the base64 functions, come from the cel-go extension libraries.
+func extensions() []cel.EnvOption {
+	return []cel.EnvOption{
+		ext.Strings(),
+		ext.Encoders(),
+		cel.Function("truncate",
+			cel.MemberOverload("string_truncate_int", []*cel.Type{cel.StringType, cel.IntType}, cel.StringType,
+				cel.BinaryBinding(truncate))),
+		cel.Function("shortSHA",
+			cel.Overload("shortSHA_string", []*cel.Type{cel.StringType}, cel.StringType,
+				cel.UnaryBinding(func(sha ref.Val) ref.Val {
+					return truncate(sha, types.Int(defaultShortSHALength))
+				})),
+			cel.MemberOverload("string_shortSHA_int", []*cel.Type{cel.StringType, cel.IntType}, cel.StringType,
+				cel.BinaryBinding(truncate))),
+		cel.Function("capture",
+			cel.MemberOverload("string_capture_string", []*cel.Type{cel.StringType, cel.StringType}, cel.StringType,
+				cel.BinaryBinding(capture))),
+		cel.Function("parseURL",
+			cel.Overload("parseURL_string", []*cel.Type{cel.StringType}, cel.MapType(cel.StringType, cel.StringType),
+				cel.UnaryBinding(parseURL))),
+		cel.Function("isSemver",
+			cel.Overload("isSemver_string", []*cel.Type{cel.StringType}, cel.BoolType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val {
+					return types.Bool(semver.IsValid(canonicalVersion(string(v.(types.String)))))
+				}))),
+		cel.Function("parseSemver",
+			cel.Overload("parseSemver_string", []*cel.Type{cel.StringType}, cel.MapType(cel.StringType, cel.DynType),
+				cel.UnaryBinding(parseSemver))),
+		cel.Function("semverCompare",
+			cel.Overload("semverCompare_string_string", []*cel.Type{cel.StringType, cel.StringType}, cel.IntType,
+				cel.BinaryBinding(semverCompare))),
+		cel.Function("formatTime",
+			cel.MemberOverload("timestamp_formatTime_string", []*cel.Type{cel.TimestampType, cel.StringType}, cel.StringType,
+				cel.BinaryBinding(func(t, layout ref.Val) ref.Val {
+					return types.String(t.(types.Timestamp).Time.Format(string(layout.(types.String))))
+				})),
+			cel.MemberOverload("string_formatTime_string", []*cel.Type{cel.StringType, cel.StringType}, cel.StringType,
+				cel.BinaryBinding(formatTimeString))),
+	}
+}
+
+// truncate returns the first n characters of the string.
+func truncate(s, n ref.Val) ref.Val {
+	runes := []rune(string(s.(types.String)))
+	l := int(n.(types.Int))
+	if l < 0 {
+		return types.NewErr("invalid length %d", l)
+	}
+	if l < len(runes) {
+		runes = runes[:l]
+	}
+	return types.String(runes)
+}
+
+// capture returns the first capture group of the regular expression in the
+// string, or the whole match if there are no groups, or an empty string if
+// there's no match.
+func capture(s, expr ref.Val) ref.Val {
+	re, err := regexp.Compile(string(expr.(types.String)))
+	if err != nil {
+		return types.NewErr("invalid regular expression: %s", err)
+	}
+	matches := re.FindStringSubmatch(string(s.(types.String)))
+	switch len(matches) {
+	case 0:
+		return types.String("")
+	case 1:
+		return types.String(matches[0])
+	}
+	return types.String(matches[1])
+}
+
+// parseURL returns the scheme, host and path of the URL, SSH style URLs e.g.
+// git@github.com:org/repo.git are also accepted.
+func parseURL(v ref.Val) ref.Val {
+	s := string(v.(types.String))
+	if !strings.Contains(s, "://") {
+		if i := strings.Index(s, ":"); i > 0 {
+			s = "ssh://" + s[:i] + "/" + s[i+1:]
+		}
+	}
+	parsed, err := url.Parse(s)
+	if err != nil {
+		return types.NewErr("failed to parse URL: %s", err)
+	}
+	return types.NewStringStringMap(types.DefaultTypeAdapter, map[string]string{
+		"scheme": parsed.Scheme,
+		"host":   parsed.Host,
+		"path":   parsed.Path,
+	})
+}
+
+// parseSemver returns the major, minor and patch numbers, and the prerelease
+// and build of a semantic version, the "v" prefix is optional.
+func parseSemver(v ref.Val) ref.Val {
+	version := canonicalVersion(string(v.(types.String)))
+	if !semver.IsValid(version) {
+		return types.NewErr("invalid semantic version %q", string(v.(types.String)))
+	}
+	core := strings.TrimPrefix(semver.Canonical(version), "v")
+	core = strings.SplitN(core, "-", 2)[0]
+	parts := strings.Split(core, ".")
+	numbers := make([]int64, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return types.NewErr("invalid semantic version %q: %s", string(v.(types.String)), err)
+		}
+		numbers[i] = n
+	}
+	return types.NewStringInterfaceMap(types.DefaultTypeAdapter, map[string]interface{}{
+		"major":      numbers[0],
+		"minor":      numbers[1],
+		"patch":      numbers[2],
+		"prerelease": strings.TrimPrefix(semver.Prerelease(version), "-"),
+		"build":      strings.TrimPrefix(semver.Build(version), "+"),
+	})
+}
+
+// semverCompare returns -1, 0 or 1 if the first version is less than, equal
+// to, or greater than the second.
+func semverCompare(a, b ref.Val) ref.Val {
+	v, w := canonicalVersion(string(a.(types.String))), canonicalVersion(string(b.(types.String)))
+	for _, s := range []string{v, w} {
+		if !semver.IsValid(s) {
+			return types.NewErr("invalid semantic version %q", strings.TrimPrefix(s, "v"))
+		}
+	}
+	return types.Int(semver.Compare(v, w))
+}
+
+// formatTimeString formats an RFC3339 timestamp with the Go time layout.
+func formatTimeString(s, layout ref.Val) ref.Val {
+	t, err := time.Parse(time.RFC3339, string(s.(types.String)))
+	if err != nil {
+		return types.NewErr("failed to parse time: %s", err)
+	}
+	return types.String(t.Format(string(layout.(types.String))))
+}
+
+func canonicalVersion(s string) string {
+	if strings.HasPrefix(s, "v") {
+		return s
+	}
+	return fmt.Sprintf("v%s", s)
+}
@@ -0,0 +1,95 @@
+package cel
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+func TestExtensionFunctions(t *testing.T) {
+	in := Input{
+		RepoURL:  testRepoURL,
+		Commit:   map[string]interface{}{"id": "testing"},
+		Ref:      "v1.2.3-rc.1+build.5",
+		PollTime: testPollTime,
+	}
+	tests := []struct {
+		expr string
+		want ref.Val
+	}{
+		{"'feature/my-branch'.split('/')[1]", types.String("my-branch")},
+		{"'Feature'.lowerAscii()", types.String("feature")},
+		{"'feature/my-branch'.replace('/', '-')", types.String("feature-my-branch")},
+		{"'testing'.substring(0, 4)", types.String("test")},
+		{"'a long commit message'.truncate(6)", types.String("a long")},
+		{"'short'.truncate(10)", types.String("short")},
+		{"'refs/tags/v1.2.3'.capture('v([0-9.]+)$')", types.String("1.2.3")},
+		{"'refs/tags/v1.2.3'.capture('v[0-9.]+$')", types.String("v1.2.3")},
+		{"'main'.capture('v([0-9.]+)$')", types.String("")},
+		{"base64.encode(b'testing')", types.String("dGVzdGluZw==")},
+		{"string(base64.decode('dGVzdGluZw=='))", types.String("testing")},
+		{"shortSHA('24317a55785cd98d6c9bf50a5204bc6be17e7316')", types.String("24317a5")},
+		{"'24317a55785cd98d6c9bf50a5204bc6be17e7316'.shortSHA(10)", types.String("24317a5578")},
+		{"parseURL(repoURL).host", types.String("example.com")},
+		{"parseURL(repoURL).path", types.String("/example/example.git")},
+		{"parseURL('git@github.com:org/repo.git').host", types.String("github.com")},
+		{"parseURL('git@github.com:org/repo.git').path", types.String("/org/repo.git")},
+		{"isSemver(ref)", types.True},
+		{"isSemver('main')", types.False},
+		{"parseSemver(ref).major", types.Int(1)},
+		{"parseSemver(ref).minor", types.Int(2)},
+		{"parseSemver(ref).patch", types.Int(3)},
+		{"parseSemver(ref).prerelease", types.String("rc.1")},
+		{"parseSemver(ref).build", types.String("build.5")},
+		{"parseSemver('2.0').patch", types.Int(0)},
+		{"semverCompare('1.2.3', 'v1.10.0')", types.Int(-1)},
+		{"semverCompare('v2.0.0', '2.0.0')", types.Int(0)},
+		{"pollTime.formatTime('20060102')", types.String("20201007")},
+		{"timestamp(pollTime).formatTime('2006-01-02 15:04')", types.String("2020-10-07 12:00")},
+		{
+			"'v%d.%d-%s'.format([parseSemver(ref).major, parseSemver(ref).minor, shortSHA('abc1234567')])",
+			types.String("v1.2-abc1234"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(rt *testing.T) {
+			ctx, err := New(in)
+			if err != nil {
+				rt.Fatal(err)
+			}
+			got, err := ctx.Evaluate(tt.expr)
+			if err != nil {
+				rt.Fatalf("Evaluate() got an error %s", err)
+			}
+			if !got.Equal(tt.want).(types.Bool) {
+				rt.Errorf("Evaluate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtensionFunctions_Error(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"'testing'.truncate(-1)", "invalid length -1"},
+		{"'testing'.capture('(')", "invalid regular expression"},
+		{"parseSemver('main')", `invalid semantic version "main"`},
+		{"semverCompare('1.0.0', 'main')", `invalid semantic version "main"`},
+		{"'yesterday'.formatTime('2006')", "failed to parse time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(rt *testing.T) {
+			ctx, err := New(Input{RepoURL: testRepoURL})
+			if err != nil {
+				rt.Fatal(err)
+			}
+			_, err = ctx.Evaluate(tt.expr)
+			if err == nil || !matchError(t, tt.want, err) {
+				rt.Errorf("Evaluate() got %v, wanted %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,20 @@
 package controller
 
 import (
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
 	"github.com/bigkevmcd/tekton-polling-operator/pkg/controller/repository"
 )
 
 func init() {
 	// AddToManagerFuncs is a list of functions to create controllers and add them to a manager.
-	AddToManagerFuncs = append(AddToManagerFuncs, repository.Add)
+	AddToManagerFuncs = append(AddToManagerFuncs, addRepository)
+}
+
+func addRepository(m manager.Manager, o Options) error {
+	return repository.Add(m, repository.Options{
+		Jitter:     o.Jitter,
+		APIVersion: o.APIVersion,
+		DryRun:     o.DryRun,
+	})
 }
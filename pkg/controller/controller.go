@@ -1,16 +1,30 @@
 package controller
 
 import (
+	"time"
+
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
 )
 
+// Options is the operator configuration that is passed to all Controllers.
+type Options struct {
+	// Jitter is the default maximum delay added to the frequency of polls.
+	Jitter time.Duration
+	// APIVersion is the default Tekton API version for created runs.
+	APIVersion pipelines.APIVersion
+	// DryRun renders runs without creating them.
+	DryRun bool
+}
+
 // AddToManagerFuncs is a list of functions to add all Controllers to the Manager
-var AddToManagerFuncs []func(manager.Manager) error
+var AddToManagerFuncs []func(manager.Manager, Options) error
 
 // AddToManager adds all Controllers to the Manager
-func AddToManager(m manager.Manager) error {
+func AddToManager(m manager.Manager, o Options) error {
 	for _, f := range AddToManagerFuncs {
-		if err := f(m); err != nil {
+		if err := f(m, o); err != nil {
 			return err
 		}
 	}
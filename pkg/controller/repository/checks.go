@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/git"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+const (
+	pipelineRunLabel  = pipeline.GroupName + pipeline.PipelineRunLabelKey
+	pipelineTaskLabel = pipeline.GroupName + pipeline.PipelineTaskLabelKey
+)
+
+type checksReporterFactory func(endpoint string, app git.GitHubApp) git.ChecksReporter
+
+// makeChecksReporter returns a factory for reporters that share the cache of
+// installation tokens.
+func makeChecksReporter(tokens *git.InstallationTokens) checksReporterFactory {
+	return func(endpoint string, app git.GitHubApp) git.ChecksReporter {
+		return git.NewGitHubChecks(http.DefaultClient, endpoint, app, tokens)
+	}
+}
+
+// reportChecks creates a GitHub check run for the run when it starts, and
+// updates it with a summary of the TaskRuns when it completes.
+//
+// The ID of the check run, and the state that was reported, are recorded on
+// the run, so that the check run is completed even if the run is superseded
+// by a newer change.
+func (r *ReconcileRunStatus) reportChecks(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository, run pipelines.RunObject, updated *pollingv1.RunStatus) error {
+	cfg := repo.Spec.ReportChecks
+	if cfg == nil || updated.SHA == "" {
+		return nil
+	}
+	state, ok := commitState(updated.Succeeded)
+	if !ok {
+		return nil
+	}
+	annotations := run.GetAnnotations()
+	id, err := checkRunID(annotations)
+	if err != nil {
+		logger.Error(err, "failed to parse the check run ID")
+		return err
+	}
+	if id != 0 && annotations[pollingv1.ReportedCheckAnnotation] == string(state) {
+		return nil
+	}
+	taskRuns, err := r.taskRuns(ctx, run)
+	if err != nil {
+		logger.Error(err, "failed to list the TaskRuns for the run")
+		return err
+	}
+	checkRun, err := makeCheckRun(repo, state, updated, taskRuns)
+	if err != nil {
+		logger.Error(err, "failed to make the check run")
+		return err
+	}
+	repoName, endpoint, err := repoFromURL(repo.Spec.URL)
+	if err != nil {
+		logger.Error(err, "Parsing the repo from the URL failed", "repoURL", repo.Spec.URL)
+		return err
+	}
+	app, err := r.githubApp(ctx, repo.Namespace, cfg.AppSecretRef)
+	if err != nil {
+		logger.Error(err, "failed to get the GitHub App credentials", "secret", cfg.AppSecretRef.Name)
+		return err
+	}
+	checks := r.checksFactory(endpoint, app)
+	if id == 0 {
+		id, err = checks.CreateCheckRun(repoName, checkRun)
+		if err != nil {
+			logger.Error(err, "failed to create the check run", "sha", updated.SHA)
+			return err
+		}
+		logger.Info("Created check run", "sha", updated.SHA, "id", id, "status", checkRun.Status)
+	} else {
+		if err := checks.UpdateCheckRun(repoName, id, checkRun); err != nil {
+			logger.Error(err, "failed to update the check run", "sha", updated.SHA, "id", id)
+			return err
+		}
+		logger.Info("Updated check run", "sha", updated.SHA, "id", id, "status", checkRun.Status)
+	}
+	// The ID is recorded as soon as the check run is created, so that a
+	// second check run isn't created for the run.
+	if err := r.annotateRun(ctx, run, map[string]string{
+		pollingv1.CheckRunIDAnnotation:    strconv.FormatInt(id, 10),
+		pollingv1.ReportedCheckAnnotation: string(state),
+	}); err != nil {
+		logger.Error(err, "failed to record the check run", "sha", updated.SHA, "id", id)
+		return err
+	}
+	return nil
+}
+
+// checkRunID returns the ID of the check run recorded in the annotations, or
+// zero if no check run has been created.
+func checkRunID(annotations map[string]string) (int64, error) {
+	v, ok := annotations[pollingv1.CheckRunIDAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse the %s annotation %q: %w", pollingv1.CheckRunIDAnnotation, v, err)
+	}
+	return id, nil
+}
+
+// taskRuns returns the TaskRuns for the run.
+//
+// The TaskRuns for a PipelineRun are listed by the label that Tekton adds to
+// them, because they're not embedded in the status of the PipelineRun with
+// the minimal embedded status, or in v1 PipelineRuns.
+func (r *ReconcileRunStatus) taskRuns(ctx context.Context, run pipelines.RunObject) ([]pipelinev1.TaskRun, error) {
+	switch v := run.(type) {
+	case *pipelinev1.PipelineRun:
+		taskRuns := &pipelinev1.TaskRunList{}
+		if err := r.client.List(ctx, taskRuns, client.InNamespace(v.Namespace), client.MatchingLabels{pipelineRunLabel: v.Name}); err != nil {
+			return nil, err
+		}
+		return taskRuns.Items, nil
+	case *pipelinev1.TaskRun:
+		return []pipelinev1.TaskRun{*v}, nil
+	}
+	return nil, nil
+}
+
+// githubApp reads the GitHub App credentials from the Secret, the namespace
+// defaults to the namespace of the Repository.
+func (r *ReconcileRunStatus) githubApp(ctx context.Context, namespace string, ref corev1.SecretReference) (git.GitHubApp, error) {
+	if ref.Namespace != "" {
+		namespace = ref.Namespace
+	}
+	id := types.NamespacedName{Name: ref.Name, Namespace: namespace}
+	values := map[string]string{}
+	for _, key := range []string{"appID", "installationID", "privateKey"} {
+		v, err := r.secretGetter.SecretToken(ctx, id, key)
+		if err != nil {
+			return git.GitHubApp{}, err
+		}
+		values[key] = v
+	}
+	appID, err := strconv.ParseInt(strings.TrimSpace(values["appID"]), 10, 64)
+	if err != nil {
+		return git.GitHubApp{}, fmt.Errorf("failed to parse the appID: %w", err)
+	}
+	installationID, err := strconv.ParseInt(strings.TrimSpace(values["installationID"]), 10, 64)
+	if err != nil {
+		return git.GitHubApp{}, fmt.Errorf("failed to parse the installationID: %w", err)
+	}
+	return git.GitHubApp{AppID: appID, InstallationID: installationID, PrivateKey: []byte(values["privateKey"])}, nil
+}
+
+func makeCheckRun(repo *pollingv1.Repository, state git.StatusState, status *pollingv1.RunStatus, taskRuns []pipelinev1.TaskRun) (git.CheckRun, error) {
+	cfg := repo.Spec.ReportChecks
+	detailsURL, err := expandURL(cfg.DetailsURL, status)
+	if err != nil {
+		return git.CheckRun{}, err
+	}
+	cr := git.CheckRun{
+		Name:       reportedName(cfg.GetName(), repo, status),
+		HeadSHA:    status.SHA,
+		DetailsURL: detailsURL,
+		ExternalID: status.Namespace + "/" + status.Name,
+		Status:     git.CheckInProgress,
+		StartedAt:  timeOf(status.StartTime),
+		Title:      runDescription(state, status),
+		Summary:    taskRunSummary(taskRuns),
+	}
+	if state == git.StatusPending {
+		return cr, nil
+	}
+	cr.Status = git.CheckCompleted
+	cr.CompletedAt = timeOf(status.CompletionTime)
+	switch {
+	case state == git.StatusSuccess:
+		cr.Conclusion = git.CheckSuccess
+	case strings.Contains(status.Reason, "Cancelled"):
+		cr.Conclusion = git.CheckCancelled
+	default:
+		cr.Conclusion = git.CheckFailure
+	}
+	return cr, nil
+}
+
+// taskRunSummary returns a Markdown table with the outcome of each of the
+// TaskRuns in the run.
+func taskRunSummary(taskRuns []pipelinev1.TaskRun) string {
+	type row struct {
+		task    string
+		taskRun string
+		status  *pipelinev1.TaskRunStatus
+	}
+	rows := []row{}
+	for i := range taskRuns {
+		tr := &taskRuns[i]
+		task := tr.Labels[pipelineTaskLabel]
+		if task == "" && tr.Spec.TaskRef != nil {
+			task = tr.Spec.TaskRef.Name
+		}
+		if task == "" {
+			task = tr.Name
+		}
+		rows = append(rows, row{task: task, taskRun: tr.Name, status: &tr.Status})
+	}
+	// The TaskRuns are listed in the order that they started.
+	sort.Slice(rows, func(i, j int) bool {
+		si, sj := timeOf(startTime(rows[i].status)), timeOf(startTime(rows[j].status))
+		if !si.Equal(sj) {
+			return si.Before(sj)
+		}
+		return rows[i].task < rows[j].task
+	})
+	if len(rows) == 0 {
+		return "No TaskRuns have been created."
+	}
+	var b strings.Builder
+	b.WriteString("| Task | TaskRun | Status | Reason | Duration |\n")
+	b.WriteString("| ---- | ------- | ------ | ------ | -------- |\n")
+	for _, r := range rows {
+		status, reason, duration := "Pending", "", ""
+		if r.status != nil {
+			if c := r.status.GetCondition("Succeeded"); c != nil {
+				status = conditionDescription(c.Status)
+				reason = c.Reason
+			}
+			if r.status.StartTime != nil && r.status.CompletionTime != nil {
+				duration = r.status.CompletionTime.Sub(r.status.StartTime.Time).Round(time.Second).String()
+			}
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", r.task, r.taskRun, status, reason, duration)
+	}
+	return b.String()
+}
+
+func conditionDescription(s corev1.ConditionStatus) string {
+	switch s {
+	case corev1.ConditionTrue:
+		return "Succeeded"
+	case corev1.ConditionFalse:
+		return "Failed"
+	}
+	return "Running"
+}
+
+func startTime(s *pipelinev1.TaskRunStatus) *metav1.Time {
+	if s == nil {
+		return nil
+	}
+	return s.StartTime
+}
+
+func timeOf(t *metav1.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.Time
+}
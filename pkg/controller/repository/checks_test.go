@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/git"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+const testAppSecretName = "test-github-app"
+
+func TestReconcileRunStatusCreatesCheckRun(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.ReportChecks = &pollingv1.ReportChecks{
+			Name:         "tekton",
+			DetailsURL:   "https://dashboard.example.com/{{.Namespace}}/{{.Name}}",
+			AppSecretRef: corev1.SecretReference{Name: testAppSecretName},
+		}
+		r.Status.LastRun = &pollingv1.RunStatus{
+			Kind:      "PipelineRun",
+			Name:      "test-run",
+			Namespace: testRepositoryNamespace,
+			SHA:       testCommitSHA,
+		}
+	})
+	pr, taskRuns := makeCheckedPipelineRun(corev1.ConditionUnknown, "Running")
+	cl, r := makeRunStatusReconciler(pipelines.PipelineRunKind, append(taskRuns, repo, pr, makeAppSecret())...)
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-run", Namespace: testRepositoryNamespace}})
+	fatalIfError(t, err)
+
+	checks := r.checksFactory("", git.GitHubApp{}).(*git.MockChecksReporter)
+	checkRun, ok := checks.CheckRun(1)
+	if !ok {
+		t.Fatal("no check run was created")
+	}
+	want := git.CheckRun{
+		Name:       "tekton",
+		HeadSHA:    testCommitSHA,
+		DetailsURL: "https://dashboard.example.com/test-repository-ns/test-run",
+		ExternalID: "test-repository-ns/test-run",
+		Status:     git.CheckInProgress,
+		StartedAt:  testStartTime.Time,
+		Title:      "The PipelineRun is running",
+		Summary: "| Task | TaskRun | Status | Reason | Duration |\n" +
+			"| ---- | ------- | ------ | ------ | -------- |\n" +
+			"| build | test-run-build | Succeeded | Succeeded | 2m0s |\n" +
+			"| test | test-run-test | Running | Running |  |\n",
+	}
+	if diff := cmp.Diff(want, checkRun); diff != "" {
+		t.Fatalf("incorrect check run:\n%s", diff)
+	}
+	loaded := &pipelinev1.PipelineRun{}
+	fatalIfError(t, cl.Get(context.Background(), types.NamespacedName{Name: "test-run", Namespace: testRepositoryNamespace}, loaded))
+	wantAnnotations := map[string]string{
+		pollingv1.CheckRunIDAnnotation:    "1",
+		pollingv1.ReportedCheckAnnotation: "pending",
+	}
+	if diff := cmp.Diff(wantAnnotations, loaded.Annotations); diff != "" {
+		t.Fatalf("incorrect run annotations:\n%s", diff)
+	}
+
+	// The check run isn't reported again until the state of the run changes.
+	_, err = r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-run", Namespace: testRepositoryNamespace}})
+	fatalIfError(t, err)
+	if _, ok := checks.CheckRun(2); ok {
+		t.Fatal("a second check run was created")
+	}
+}
+
+func TestReconcileRunStatusCompletesCheckRun(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.ReportChecks = &pollingv1.ReportChecks{
+			AppSecretRef: corev1.SecretReference{Name: testAppSecretName},
+		}
+		r.Status.LastRun = &pollingv1.RunStatus{
+			Kind:      "PipelineRun",
+			Name:      "test-run",
+			Namespace: testRepositoryNamespace,
+			SHA:       testCommitSHA,
+			Succeeded: corev1.ConditionUnknown,
+		}
+	})
+	pr, taskRuns := makeCheckedPipelineRun(corev1.ConditionFalse, "Failed")
+	pr.Annotations = map[string]string{
+		pollingv1.CheckRunIDAnnotation:    "1",
+		pollingv1.ReportedCheckAnnotation: "pending",
+	}
+	pr.Status.CompletionTime = &testCompletionTime
+	_, r := makeRunStatusReconciler(pipelines.PipelineRunKind, append(taskRuns, repo, pr, makeAppSecret())...)
+	checks := r.checksFactory("", git.GitHubApp{}).(*git.MockChecksReporter)
+	_, err := checks.CreateCheckRun(testRepo, git.CheckRun{Name: pollingv1.DefaultStatusContext})
+	fatalIfError(t, err)
+
+	_, err = r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-run", Namespace: testRepositoryNamespace}})
+	fatalIfError(t, err)
+
+	checkRun, _ := checks.CheckRun(1)
+	if checkRun.Status != git.CheckCompleted || checkRun.Conclusion != git.CheckFailure {
+		t.Fatalf("got check run %s/%s, want completed/failure", checkRun.Status, checkRun.Conclusion)
+	}
+	if !checkRun.CompletedAt.Equal(testCompletionTime.Time) {
+		t.Fatalf("got CompletedAt %s, want %s", checkRun.CompletedAt, testCompletionTime)
+	}
+	if checkRun.Title != "The PipelineRun failed: Failed" {
+		t.Fatalf("got Title %q", checkRun.Title)
+	}
+}
+
+func TestReconcileRunStatusCompletesSupersededCheckRun(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.ReportChecks = &pollingv1.ReportChecks{
+			AppSecretRef: corev1.SecretReference{Name: testAppSecretName},
+		}
+		// The run for a newer commit started before this run completed.
+		r.Status.LastRun = &pollingv1.RunStatus{
+			Kind:      "PipelineRun",
+			Name:      "new-run",
+			Namespace: testRepositoryNamespace,
+			SHA:       "7d5c2fe6b3f3b6ac1a2a0c1a5d2e0b5f8c9d7e6a",
+			Succeeded: corev1.ConditionUnknown,
+		}
+	})
+	pr, taskRuns := makeCheckedPipelineRun(corev1.ConditionTrue, "Succeeded")
+	pr.Annotations = map[string]string{
+		pollingv1.CheckRunIDAnnotation:    "1",
+		pollingv1.ReportedCheckAnnotation: "pending",
+	}
+	pr.Status.CompletionTime = &testCompletionTime
+	cl, r := makeRunStatusReconciler(pipelines.PipelineRunKind, append(taskRuns, repo, pr, makeAppSecret())...)
+	checks := r.checksFactory("", git.GitHubApp{}).(*git.MockChecksReporter)
+	_, err := checks.CreateCheckRun(testRepo, git.CheckRun{Name: pollingv1.DefaultStatusContext})
+	fatalIfError(t, err)
+
+	_, err = r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-run", Namespace: testRepositoryNamespace}})
+	fatalIfError(t, err)
+
+	checkRun, _ := checks.CheckRun(1)
+	if checkRun.Status != git.CheckCompleted || checkRun.Conclusion != git.CheckSuccess {
+		t.Fatalf("got check run %s/%s, want completed/success", checkRun.Status, checkRun.Conclusion)
+	}
+	if _, ok := checks.CheckRun(2); ok {
+		t.Fatal("a second check run was created")
+	}
+	loaded := &pipelinev1.PipelineRun{}
+	fatalIfError(t, cl.Get(context.Background(), types.NamespacedName{Name: "test-run", Namespace: testRepositoryNamespace}, loaded))
+	if a := loaded.Annotations[pollingv1.ReportedCheckAnnotation]; a != "success" {
+		t.Fatalf("got reported check %q, want %q", a, "success")
+	}
+}
+
+func TestGitHubApp(t *testing.T) {
+	_, r := makeRunStatusReconciler(pipelines.PipelineRunKind, makeAppSecret())
+
+	app, err := r.githubApp(context.Background(), testRepositoryNamespace, corev1.SecretReference{Name: testAppSecretName})
+	fatalIfError(t, err)
+
+	want := git.GitHubApp{AppID: 1234, InstallationID: 5678, PrivateKey: []byte("test-key")}
+	if diff := cmp.Diff(want, app); diff != "" {
+		t.Fatalf("incorrect GitHub App:\n%s", diff)
+	}
+}
+
+// makeCheckedPipelineRun returns a PipelineRun with the minimal embedded
+// status, and the TaskRuns that it created.
+func makeCheckedPipelineRun(status corev1.ConditionStatus, reason string) (*pipelinev1.PipelineRun, []runtime.Object) {
+	buildStart := metav1.NewTime(testStartTime.Add(time.Second))
+	buildEnd := metav1.NewTime(buildStart.Add(time.Minute * 2))
+	testStart := metav1.NewTime(buildEnd.Add(time.Second))
+	pr := &pipelinev1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-run",
+			Namespace: testRepositoryNamespace,
+			Labels:    testCreatedRunLabels,
+		},
+	}
+	pr.Status.StartTime = &testStartTime
+	pr.Status.Conditions = duckv1beta1.Conditions{
+		{Type: apis.ConditionSucceeded, Status: status, Reason: reason},
+	}
+	build := makeCheckedTaskRun("test-run-build", "test-run", "build")
+	build.Status.StartTime = &buildStart
+	build.Status.CompletionTime = &buildEnd
+	build.Status.Conditions = duckv1beta1.Conditions{
+		{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue, Reason: "Succeeded"},
+	}
+	test := makeCheckedTaskRun("test-run-test", "test-run", "test")
+	test.Status.StartTime = &testStart
+	test.Status.Conditions = duckv1beta1.Conditions{
+		{Type: apis.ConditionSucceeded, Status: corev1.ConditionUnknown, Reason: "Running"},
+	}
+	// This TaskRun was created by a different PipelineRun.
+	other := makeCheckedTaskRun("other-run-build", "other-run", "build")
+	other.Status.StartTime = &buildStart
+	return pr, []runtime.Object{test, build, other}
+}
+
+func makeCheckedTaskRun(name, pipelineRun, pipelineTask string) *pipelinev1.TaskRun {
+	return &pipelinev1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testRepositoryNamespace,
+			Labels: map[string]string{
+				"tekton.dev/pipelineRun":  pipelineRun,
+				"tekton.dev/pipelineTask": pipelineTask,
+			},
+		},
+	}
+}
+
+func makeAppSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testAppSecretName,
+			Namespace: testRepositoryNamespace,
+		},
+		Data: map[string][]byte{
+			"appID":          []byte("1234"),
+			"installationID": []byte("5678\n"),
+			"privateKey":     []byte("test-key"),
+		},
+	}
+}
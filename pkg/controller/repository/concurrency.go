@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+// applyConcurrencyPolicy returns true if the runs should be created for the
+// change, with the Replace policy, the active runs for the targets are
+// cancelled first.
+//
+// With the Queue policy, the change is recorded in the DeferredSHA and a run
+// is created once the active runs have completed.
+func (r *ReconcileRepository) applyConcurrencyPolicy(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository, targets []runTarget) (bool, error) {
+	policy := repo.Spec.ConcurrencyPolicy
+	if policy == "" || policy == pollingv1.AllowConcurrent {
+		return true, nil
+	}
+	active := []*unstructured.Unstructured{}
+	for _, t := range targets {
+		selector := targetSelector(t.ns, t.opts)
+		runs, err := r.runner.ListRuns(ctx, selector.ns, selector.kind, t.opts.APIVersion, selector.labels)
+		if err != nil {
+			logger.Error(err, "failed to list the active runs")
+			return false, err
+		}
+		for _, run := range runs {
+			if !pipelines.IsDone(run) {
+				active = append(active, run)
+			}
+		}
+	}
+	if len(active) == 0 {
+		return true, nil
+	}
+	sha := repo.Status.PollStatus.SHA
+	switch policy {
+	case pollingv1.ForbidConcurrent:
+		logger.Info("Runs are active, skipping change", "sha", sha, "active", len(active))
+		repo.Status.DeferredSHA = ""
+		return false, nil
+	case pollingv1.QueueConcurrent:
+		logger.Info("Runs are active, queueing change", "sha", sha, "active", len(active))
+		repo.Status.DeferredSHA = sha
+		return false, nil
+	case pollingv1.ReplaceConcurrent:
+		for _, run := range active {
+			if err := r.runner.Cancel(ctx, run); err != nil {
+				logger.Error(err, "failed to cancel an active run", "name", run.GetName())
+				return false, err
+			}
+			logger.Info("Cancelled active run", "kind", run.GetKind(), "name", run.GetName())
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+func TestReconcileRepositoryWithConcurrencyPolicy(t *testing.T) {
+	policyTests := []struct {
+		policy        pollingv1.ConcurrencyPolicy
+		runs          []*unstructured.Unstructured
+		wantRun       bool
+		wantCancelled []string
+		wantDeferred  string
+	}{
+		{pollingv1.AllowConcurrent, []*unstructured.Unstructured{makeTestRun("active", "Unknown")}, true, nil, ""},
+		{pollingv1.ForbidConcurrent, []*unstructured.Unstructured{makeTestRun("active", "Unknown")}, false, nil, ""},
+		{pollingv1.ForbidConcurrent, []*unstructured.Unstructured{makeTestRun("done", "True")}, true, nil, ""},
+		{pollingv1.QueueConcurrent, []*unstructured.Unstructured{makeTestRun("active", "Unknown")}, false, nil, testCommitSHA},
+		{pollingv1.QueueConcurrent, []*unstructured.Unstructured{makeTestRun("failed", "False")}, true, nil, ""},
+		{
+			pollingv1.ReplaceConcurrent,
+			[]*unstructured.Unstructured{makeTestRun("active", "Unknown"), makeTestRun("done", "True"), makeTestRun("pending", "")},
+			true,
+			[]string{testRepositoryNamespace + ":active", testRepositoryNamespace + ":pending"},
+			"",
+		},
+	}
+
+	for _, tt := range policyTests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			logf.SetLogger(logf.ZapLogger(true))
+			ctx := context.Background()
+			repo := makeRepository(func(r *pollingv1.Repository) {
+				r.Spec.ConcurrencyPolicy = tt.policy
+			})
+			cl, r := makeReconciler(t, repo, repo)
+			runner := r.runner.(*pipelines.MockRunner)
+			runner.AddRuns(tt.runs...)
+			req := makeReconcileRequest()
+
+			_, err := r.Reconcile(req)
+			fatalIfError(t, err)
+
+			if tt.wantRun {
+				runner.AssertPipelineRun(
+					testPipelineName, testRepositoryNamespace, testServiceAccountName,
+					makeTestParams(map[string]string{"one": testRepoURL, "two": "main"}),
+					testResources, testWorkspaces)
+			} else {
+				runner.AssertNoRuns()
+			}
+			runner.AssertCancelled(tt.wantCancelled...)
+			loaded := &pollingv1.Repository{}
+			err = cl.Get(ctx, req.NamespacedName, loaded)
+			fatalIfError(t, err)
+			if loaded.Status.PollStatus.SHA != testCommitSHA {
+				t.Fatalf("change was not recorded, got SHA %q", loaded.Status.PollStatus.SHA)
+			}
+			if loaded.Status.DeferredSHA != tt.wantDeferred {
+				t.Fatalf("got DeferredSHA %q, want %q", loaded.Status.DeferredSHA, tt.wantDeferred)
+			}
+		})
+	}
+}
+
+func makeTestRun(name, succeeded string) *unstructured.Unstructured {
+	run := &unstructured.Unstructured{}
+	run.SetAPIVersion("tekton.dev/v1beta1")
+	run.SetKind("PipelineRun")
+	run.SetName(name)
+	run.SetNamespace(testRepositoryNamespace)
+	run.SetLabels(testRunLabels)
+	if succeeded != "" {
+		run.Object["status"] = map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Succeeded", "status": succeeded},
+			},
+		}
+	}
+	return run
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/cel"
+)
+
+// renderRuns renders the runs that would be created for the commit, and
+// records them in the DryRun status, without creating them.
+//
+// The concurrency policy isn't applied, and no PendingTrigger is recorded,
+// because nothing is created.
+func (r *ReconcileRepository) renderRuns(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository, celctx *cel.Context) error {
+	var rendered []pollingv1.RenderedRun
+	var err error
+	if repo.Spec.TriggerTemplate != nil {
+		rendered, err = r.renderTriggerTemplate(ctx, logger, repo, celctx)
+	} else {
+		rendered, err = r.renderTargets(ctx, logger, repo, celctx)
+	}
+	if err != nil {
+		return err
+	}
+	repo.Status.DeferredSHA = ""
+	repo.Status.PendingTrigger = nil
+	repo.Status.DryRun = &pollingv1.DryRunStatus{SHA: repo.Status.PollStatus.SHA, Runs: rendered}
+	logger.Info("Dry run, runs rendered but not created", "sha", repo.Status.PollStatus.SHA, "runs", len(rendered))
+	return r.updateStatus(ctx, logger, repo)
+}
+
+func (r *ReconcileRepository) renderTargets(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository, celctx *cel.Context) ([]pollingv1.RenderedRun, error) {
+	targets, err := r.runTargets(ctx, celctx, repo)
+	if err != nil {
+		logger.Error(err, "failed to parse the parameters")
+		return nil, err
+	}
+	targets, err = r.validateTargets(ctx, logger, repo, targets)
+	if err != nil {
+		return nil, err
+	}
+	rendered := []pollingv1.RenderedRun{}
+	for _, t := range targets {
+		t.opts.APIVersion = r.runAPIVersion(repo, t.opts)
+		run, err := r.runner.Render(t.ns, t.opts)
+		if err != nil {
+			logger.Error(err, "failed to render a run", "kind", t.opts.Kind, "name", t.opts.Name)
+			return nil, err
+		}
+		manifest, err := renderManifest(run)
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, pollingv1.RenderedRun{
+			Kind:      string(t.opts.Kind),
+			Namespace: t.ns,
+			Pipeline:  t.opts.Labels[pollingv1.PipelineLabel],
+			Manifest:  manifest,
+		})
+	}
+	return rendered, nil
+}
+
+func (r *ReconcileRepository) renderTriggerTemplate(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository, celctx *cel.Context) ([]pollingv1.RenderedRun, error) {
+	template := triggerTemplateName(repo)
+	params, err := makeBindings(celctx, repo.Spec.Bindings)
+	if err != nil {
+		logger.Error(err, "failed to parse the bindings")
+		return nil, err
+	}
+	resources, err := r.templateRunner.RenderTemplate(ctx, template, params)
+	if err != nil {
+		logger.Error(err, "failed to render the trigger template", "template", template)
+		return nil, err
+	}
+	rendered := []pollingv1.RenderedRun{}
+	for _, res := range resources {
+		manifest, err := renderManifest(res)
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, pollingv1.RenderedRun{
+			Kind:      res.GetKind(),
+			Namespace: res.GetNamespace(),
+			Manifest:  manifest,
+		})
+	}
+	return rendered, nil
+}
+
+// renderManifest returns the YAML for the object, the empty status and
+// creationTimestamp of unsaved objects are dropped.
+func renderManifest(obj runtime.Object) (string, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return "", fmt.Errorf("failed to convert the rendered run: %w", err)
+		}
+		u = &unstructured.Unstructured{Object: content}
+	}
+	u = u.DeepCopy()
+	unstructured.RemoveNestedField(u.Object, "status")
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	b, err := yaml.Marshal(u.Object)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the rendered run: %w", err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/triggers"
+)
+
+func TestReconcileRepositoryWithDryRun(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.DryRun = true
+	})
+	cl, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	m := r.runner.(*pipelines.MockRunner)
+	m.AssertNoRuns()
+	m.AssertRendered(testPipelineName, testRepositoryNamespace, pipelines.RunOptions{
+		Kind:               pipelines.PipelineRunKind,
+		Name:               testPipelineName,
+		ServiceAccountName: testServiceAccountName,
+		Params:             makeTestParams(map[string]string{"one": testRepoURL, "two": "main"}),
+		Resources:          testResources,
+		Workspaces:         testWorkspaces,
+		Labels: map[string]string{
+			pollingv1.RepositoryLabel:          testRepositoryName,
+			pollingv1.RepositoryNamespaceLabel: testRepositoryNamespace,
+			pollingv1.SHALabel:                 testCommitSHA,
+			pollingv1.RefLabel:                 testRef,
+		},
+		OwnerReferences: []metav1.OwnerReference{ownerReference(repo)},
+	})
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), req.NamespacedName, loaded))
+	want := &pollingv1.DryRunStatus{
+		SHA: testCommitSHA,
+		Runs: []pollingv1.RenderedRun{
+			{
+				Kind:      "PipelineRun",
+				Namespace: testRepositoryNamespace,
+				Manifest: `apiVersion: tekton.dev/v1beta1
+kind: PipelineRun
+metadata:
+  labels:
+    polling.tekton.dev/ref: main
+    polling.tekton.dev/repository: test-repository
+    polling.tekton.dev/repository-namespace: test-repository-ns
+    polling.tekton.dev/sha: 24317a55785cd98d6c9bf50a5204bc6be17e7316
+  name: test-pipeline
+  namespace: test-repository-ns
+spec: {}
+`,
+			},
+		},
+	}
+	if diff := cmp.Diff(want, loaded.Status.DryRun); diff != "" {
+		t.Fatalf("incorrect dry run status:\n%s", diff)
+	}
+	if loaded.Status.LastRun != nil || loaded.Status.PendingTrigger != nil {
+		t.Fatalf("dry run recorded a run: %#v", loaded.Status)
+	}
+}
+
+func TestReconcileRepositoryWithDryRunDoesNotPruneRuns(t *testing.T) {
+	dryRunTests := []struct {
+		name         string
+		repoDryRun   bool
+		operatorMode bool
+	}{
+		{"repository dry run", true, false},
+		{"operator dry run", false, true},
+	}
+
+	for _, tt := range dryRunTests {
+		t.Run(tt.name, func(t *testing.T) {
+			logf.SetLogger(logf.ZapLogger(true))
+			repo := makeRepository(func(r *pollingv1.Repository) {
+				r.Spec.DryRun = tt.repoDryRun
+				r.Spec.SuccessfulRunsHistoryLimit = int32Ptr(0)
+				r.Spec.FailedRunsHistoryLimit = int32Ptr(0)
+			})
+			_, r := makeReconciler(t, repo, repo)
+			r.dryRun = tt.operatorMode
+			runner := r.runner.(*pipelines.MockRunner)
+			runner.AddRuns(
+				withCompletionTime(makeTestRun("succeeded", "True"), "2020-10-01T10:00:00Z"),
+				withCompletionTime(makeTestRun("failed", "False"), "2020-10-01T10:00:00Z"),
+			)
+
+			_, err := r.Reconcile(makeReconcileRequest())
+			fatalIfError(t, err)
+			r.pruneRuns(context.Background(), r.log, repo)
+
+			runner.AssertNoRuns()
+			runner.AssertDeleted()
+		})
+	}
+}
+
+func TestReconcileRepositoryWithOperatorDryRun(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository()
+	cl, r := makeReconciler(t, repo, repo)
+	r.dryRun = true
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), req.NamespacedName, loaded))
+	if l := len(loaded.Status.DryRun.Runs); l != 1 {
+		t.Fatalf("got %d rendered runs, want 1", l)
+	}
+}
+
+func TestReconcileRepositoryWithTriggerTemplateDryRun(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline = pollingv1.PipelineRef{}
+		r.Spec.TriggerTemplate = &pollingv1.TriggerTemplateRef{Name: "test-template"}
+		r.Spec.Bindings = []pollingv1.Param{{Name: "sha", Expression: "commit.id"}}
+		r.Spec.DryRun = true
+	})
+	cl, r := makeReconciler(t, repo, repo)
+	m := r.templateRunner.(*triggers.MockRunner)
+	pr := &unstructured.Unstructured{}
+	pr.SetAPIVersion("tekton.dev/v1beta1")
+	pr.SetKind("PipelineRun")
+	pr.SetGenerateName("test-run-")
+	pr.SetNamespace(testRepositoryNamespace)
+	m.AddResources(pr)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	m.AssertNoTemplateRuns()
+	template := types.NamespacedName{Name: "test-template", Namespace: testRepositoryNamespace}
+	m.AssertTemplateRendered(template, map[string]string{"sha": "main"})
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), req.NamespacedName, loaded))
+	want := &pollingv1.DryRunStatus{
+		SHA: testCommitSHA,
+		Runs: []pollingv1.RenderedRun{
+			{
+				Kind:      "PipelineRun",
+				Namespace: testRepositoryNamespace,
+				Manifest:  "apiVersion: tekton.dev/v1beta1\nkind: PipelineRun\nmetadata:\n  generateName: test-run-\n  namespace: test-repository-ns\n",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, loaded.Status.DryRun); diff != "" {
+		t.Fatalf("incorrect dry run status:\n%s", diff)
+	}
+}
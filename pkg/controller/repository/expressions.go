@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/cel"
+)
+
+// compileExpressions compiles all the expressions in the Repository, and
+// updates the ExpressionsValid condition with the outcome.
+//
+// The compiled programs are cached, so they're not compiled again when the
+// Repository is polled, it returns false if any of the expressions fail to
+// compile.
+func compileExpressions(logger logr.Logger, repo *pollingv1.Repository) bool {
+	problems := expressionProblems(repo.Spec)
+	repo.Status.ObservedGeneration = repo.Generation
+	if len(problems) > 0 {
+		message := strings.Join(problems, "; ")
+		logger.Info("Repository expressions failed to compile", "message", message)
+		meta.SetStatusCondition(&repo.Status.Conditions, metav1.Condition{
+			Type:    pollingv1.ExpressionsValidCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  pollingv1.CompileErrorReason,
+			Message: message,
+		})
+		return false
+	}
+	meta.SetStatusCondition(&repo.Status.Conditions, metav1.Condition{
+		Type:    pollingv1.ExpressionsValidCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  pollingv1.ExpressionsCompiledReason,
+		Message: "All the expressions compiled",
+	})
+	return true
+}
+
+// expressionProblems returns the compile errors for the expressions in the
+// spec, prefixed with the path to the expression.
+func expressionProblems(spec pollingv1.RepositorySpec) []string {
+	problems := []string{}
+	compileParams := func(path string, params []pollingv1.Param) {
+		for i, p := range params {
+			if err := cel.Compile(p.Expression); err != nil {
+				problems = append(problems, fmt.Sprintf("%s[%d]: %s", path, i, compileError(err)))
+			}
+		}
+	}
+	compileParams("pipelineRef.params", spec.Pipeline.Params)
+	if spec.Task != nil {
+		compileParams("taskRef.params", spec.Task.Params)
+	}
+	for i, p := range spec.Pipelines {
+		compileParams(fmt.Sprintf("pipelines[%d].pipelineRef.params", i), p.PipelineRef.Params)
+		if p.Filter == "" {
+			continue
+		}
+		if err := cel.CompileFilter(p.Filter); err != nil {
+			problems = append(problems, fmt.Sprintf("pipelines[%d].filter: %s", i, compileError(err)))
+		}
+	}
+	compileParams("bindings", spec.Bindings)
+	return problems
+}
+
+// compileError returns the errors from the CEL compiler on a single line for
+// the condition message, without the snippets of the expression that are
+// included for each error.
+func compileError(err error) string {
+	errs := []string{}
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if strings.HasPrefix(line, " |") {
+			continue
+		}
+		errs = append(errs, line)
+	}
+	return strings.Join(errs, ", ")
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+func TestReconcileRepositoryCompilesExpressions(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	ctx := context.Background()
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Generation = 2
+	})
+	cl, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	if loaded.Status.ObservedGeneration != 2 {
+		t.Fatalf("got ObservedGeneration %d, want 2", loaded.Status.ObservedGeneration)
+	}
+	want := []metav1.Condition{
+		{
+			Type:    pollingv1.ExpressionsValidCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  pollingv1.ExpressionsCompiledReason,
+			Message: "All the expressions compiled",
+		},
+		{
+			Type:    pollingv1.PipelineValidCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  pollingv1.PipelineValidReason,
+			Message: "The params match the params declared by the Pipeline",
+		},
+	}
+	if diff := cmp.Diff(want, loaded.Status.Conditions, ignoreTransitionTime); diff != "" {
+		t.Fatalf("incorrect conditions:\n%s", diff)
+	}
+	r.runner.(*pipelines.MockRunner).AssertPipelineRun(
+		testPipelineName, testRepositoryNamespace,
+		testServiceAccountName,
+		makeTestParams(map[string]string{"one": testRepoURL, "two": "main"}),
+		testResources, testWorkspaces)
+}
+
+func TestReconcileRepositoryWithInvalidExpressions(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	ctx := context.Background()
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Generation = 1
+		r.Spec.Pipeline.Params[1].Expression = "commit.id +"
+	})
+	cl, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	res, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	if diff := cmp.Diff(reconcile.Result{}, res); diff != "" {
+		t.Fatalf("reconciliation result is different:\n%s", diff)
+	}
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	cond := meta.FindStatusCondition(loaded.Status.Conditions, pollingv1.ExpressionsValidCondition)
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != pollingv1.CompileErrorReason {
+		t.Fatalf("got condition %#v, want a compile error", cond)
+	}
+	wantPrefix := "pipelineRef.params[1]: ERROR: <input>:1:12: Syntax error"
+	if !strings.HasPrefix(cond.Message, wantPrefix) {
+		t.Fatalf("got message %q, want prefix %q", cond.Message, wantPrefix)
+	}
+
+	// The expressions aren't compiled again until the Repository changes, and
+	// the Repository isn't polled.
+	res, err = r.Reconcile(req)
+	fatalIfError(t, err)
+	if diff := cmp.Diff(reconcile.Result{}, res); diff != "" {
+		t.Fatalf("reconciliation result is different:\n%s", diff)
+	}
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+}
+
+func TestExpressionProblems(t *testing.T) {
+	spec := pollingv1.RepositorySpec{
+		Pipelines: []pollingv1.PipelineTarget{
+			{
+				Name: "first",
+				PipelineRef: pollingv1.PipelineRef{
+					Params: []pollingv1.Param{{Name: "sha", Expression: "head.sha"}},
+				},
+				Filter: "ref == 'main'",
+			},
+			{
+				Name: "second",
+				PipelineRef: pollingv1.PipelineRef{
+					Params: []pollingv1.Param{{Name: "sha", Expression: "unknown.sha"}},
+				},
+				Filter: "ref",
+			},
+		},
+		Task: &pollingv1.TaskRef{
+			Params: []pollingv1.Param{{Name: "sha", Expression: "head.sha.truncate('7')"}},
+		},
+		Bindings: []pollingv1.Param{{Name: "sha", Expression: "head.sha"}},
+	}
+
+	problems := expressionProblems(spec)
+
+	want := []string{
+		"taskRef.params[0]: ERROR: <input>:1:18: found no matching overload for 'truncate' applied to 'string.(string)'",
+		"pipelines[1].pipelineRef.params[0]: ERROR: <input>:1:1: undeclared reference to 'unknown' (in container '')",
+		"pipelines[1].filter: expression must evaluate to a bool, not string",
+	}
+	if diff := cmp.Diff(want, problems); diff != "" {
+		t.Fatalf("expressionProblems() failed:\n%s", diff)
+	}
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+// pruneRuns deletes the oldest completed runs that were created for the
+// Repository, keeping the number configured in the history limits, for each
+// of the Repository's Pipelines.
+//
+// This lists the runs from the API server, so it's only called when new runs
+// are created, rather than on every poll, runs that complete later are pruned
+// when the next runs are created.
+//
+// Failing to prune runs doesn't prevent polling, so errors are only logged.
+func (r *ReconcileRepository) pruneRuns(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository) {
+	if repo.Spec.SuccessfulRunsHistoryLimit == nil && repo.Spec.FailedRunsHistoryLimit == nil {
+		return
+	}
+	if r.isDryRun(repo) {
+		logger.Info("Not pruning runs in dry-run mode")
+		return
+	}
+	selectors, err := r.runSelectors(ctx, repo)
+	if err != nil {
+		logger.Error(err, "failed to find the runs to prune")
+		return
+	}
+	for _, selector := range selectors {
+		r.pruneSelectedRuns(ctx, logger, repo, selector)
+	}
+}
+
+// pruneSelectedRuns applies the history limits to the runs that match the
+// selector.
+func (r *ReconcileRepository) pruneSelectedRuns(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository, selector runSelector) {
+	runs, err := r.runner.ListRuns(ctx, selector.ns, selector.kind, r.apiVersionFor(repo), selector.labels)
+	if err != nil {
+		logger.Error(err, "failed to list the runs to prune")
+		return
+	}
+	succeeded := []*unstructured.Unstructured{}
+	failed := []*unstructured.Unstructured{}
+	for _, run := range runs {
+		if !pipelines.IsDone(run) {
+			continue
+		}
+		if pipelines.IsSucceeded(run) {
+			succeeded = append(succeeded, run)
+		} else {
+			failed = append(failed, run)
+		}
+	}
+	r.deleteOldest(ctx, logger, succeeded, repo.Spec.SuccessfulRunsHistoryLimit)
+	r.deleteOldest(ctx, logger, failed, repo.Spec.FailedRunsHistoryLimit)
+}
+
+func (r *ReconcileRepository) deleteOldest(ctx context.Context, logger logr.Logger, runs []*unstructured.Unstructured, limit *int32) {
+	if limit == nil || len(runs) <= int(*limit) {
+		return
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return completedAt(runs[i]).After(completedAt(runs[j]))
+	})
+	for _, run := range runs[*limit:] {
+		if err := r.runner.Delete(ctx, run); err != nil {
+			logger.Error(err, "failed to prune a run", "kind", run.GetKind(), "name", run.GetName())
+			continue
+		}
+		logger.Info("Pruned run", "kind", run.GetKind(), "name", run.GetName())
+	}
+}
+
+func completedAt(run *unstructured.Unstructured) time.Time {
+	if t := pipelines.CompletionTime(run); !t.IsZero() {
+		return t
+	}
+	return run.GetCreationTimestamp().Time
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/git"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+func TestReconcileRepositoryPrunesRuns(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.SuccessfulRunsHistoryLimit = int32Ptr(1)
+		r.Spec.FailedRunsHistoryLimit = int32Ptr(0)
+	})
+	_, r := makeReconciler(t, repo, repo)
+	runner := r.runner.(*pipelines.MockRunner)
+	runner.AddRuns(
+		withCompletionTime(makeTestRun("succeeded-old", "True"), "2020-10-01T10:00:00Z"),
+		withCompletionTime(makeTestRun("succeeded-new", "True"), "2020-10-02T10:00:00Z"),
+		withCompletionTime(makeTestRun("succeeded-oldest", "True"), "2020-09-01T10:00:00Z"),
+		withCompletionTime(makeTestRun("failed", "False"), "2020-10-01T10:00:00Z"),
+		makeTestRun("active", "Unknown"),
+	)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	runner.AssertDeleted(
+		testRepositoryNamespace+":succeeded-old",
+		testRepositoryNamespace+":succeeded-oldest",
+		testRepositoryNamespace+":failed",
+	)
+}
+
+func TestReconcileRepositoryDoesNotPruneUnchangedPolls(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	polled := pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA, ETag: testCommitETag}
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.SuccessfulRunsHistoryLimit = int32Ptr(0)
+		r.Spec.FailedRunsHistoryLimit = int32Ptr(0)
+		r.Status.PollStatus = polled
+	})
+	_, r := makeReconciler(t, repo, repo)
+	p := git.NewMockPoller()
+	p.AddMockResponse(testRepo, polled, map[string]interface{}{"id": testRef}, polled)
+	r.pollerFactory = func(*pollingv1.Repository, string, string) git.CommitPoller {
+		return p
+	}
+	runner := r.runner.(*pipelines.MockRunner)
+	runner.AddRuns(makeTestRun("succeeded", "True"), makeTestRun("failed", "False"))
+
+	_, err := r.Reconcile(makeReconcileRequest())
+	fatalIfError(t, err)
+
+	runner.AssertNoRuns()
+	runner.AssertDeleted()
+}
+
+func TestReconcileRepositoryWithoutHistoryLimits(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository()
+	_, r := makeReconciler(t, repo, repo)
+	runner := r.runner.(*pipelines.MockRunner)
+	runner.AddRuns(makeTestRun("succeeded", "True"), makeTestRun("failed", "False"))
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	runner.AssertDeleted()
+}
+
+func withCompletionTime(run *unstructured.Unstructured, t string) *unstructured.Unstructured {
+	_ = unstructured.SetNestedField(run.Object, t, "status", "completionTime")
+	return run
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
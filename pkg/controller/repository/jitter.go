@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"hash/fnv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+)
+
+// nextPoll returns the delay until the next poll of the repository, this is
+// the configured frequency with the jitter for the repository added.
+func (r *ReconcileRepository) nextPoll(repo *pollingv1.Repository) time.Duration {
+	return repo.GetFrequency() + jitterFor(repo, r.maxJitter(repo))
+}
+
+// startupDelay returns the jitter for repositories that have already been
+// polled, the first time they're reconciled by this controller, this spreads
+// out the polls that would otherwise all happen when the controller starts.
+func (r *ReconcileRepository) startupDelay(repo *pollingv1.Repository) time.Duration {
+	r.seenMu.Lock()
+	defer r.seenMu.Unlock()
+	key := types.NamespacedName{Name: repo.Name, Namespace: repo.Namespace}
+	if r.seen[key] {
+		return 0
+	}
+	r.seen[key] = true
+	if repo.Status.PollStatus.SHA == "" {
+		return 0
+	}
+	return jitterFor(repo, r.maxJitter(repo))
+}
+
+func (r *ReconcileRepository) maxJitter(repo *pollingv1.Repository) time.Duration {
+	if repo.Spec.Jitter != nil {
+		return repo.Spec.Jitter.Duration
+	}
+	return r.jitter
+}
+
+// jitterFor returns a delay between 0 and max, the delay is derived from the
+// namespace and name of the repository, so it's stable for each repository.
+func jitterFor(repo *pollingv1.Repository, max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(repo.Namespace + "/" + repo.Name))
+	return time.Duration(h.Sum64() % uint64(max))
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitterFor(t *testing.T) {
+	jitterTests := []struct {
+		name string
+		max  time.Duration
+		want time.Duration
+	}{
+		{testRepositoryName, 0, 0},
+		{testRepositoryName, time.Second * 5, time.Duration(2300356552)},
+		{testRepositoryName, time.Minute, time.Duration(27300356552)},
+		{"another-repository", time.Minute, time.Duration(59014074427)},
+	}
+
+	for _, tt := range jitterTests {
+		repo := makeRepository()
+		repo.Name = tt.name
+		if got := jitterFor(repo, tt.max); got != tt.want {
+			t.Errorf("jitterFor(%s, %s) got %s, want %s", tt.name, tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+// validateTargets checks the params for each of the targets against the
+// params declared by the Pipeline, and returns the targets that are valid.
+//
+// The PipelineValid condition is updated with the outcome, Pipelines that are
+// fetched from bundles or remote resolvers can't be checked.
+func (r *ReconcileRepository) validateTargets(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository, targets []runTarget) ([]runTarget, error) {
+	valid := []runTarget{}
+	problems := []string{}
+	reason := ""
+	checked := false
+	for _, t := range targets {
+		spec, err := r.pipelineSpecFor(ctx, t)
+		if errors.IsNotFound(err) {
+			problems = append(problems, fmt.Sprintf("Pipeline %s/%s not found", t.ns, t.opts.Name))
+			if reason == "" {
+				reason = pollingv1.PipelineNotFoundReason
+			}
+			continue
+		}
+		if err != nil {
+			logger.Error(err, "failed to get the Pipeline", "namespace", t.ns, "name", t.opts.Name)
+			return nil, err
+		}
+		if spec == nil {
+			valid = append(valid, t)
+			continue
+		}
+		checked = true
+		if invalid := invalidParams(spec.Params, suppliedParams(t.opts)); len(invalid) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: %s", describePipeline(t), strings.Join(invalid, ", ")))
+			if reason == "" {
+				reason = pollingv1.InvalidParamsReason
+			}
+			continue
+		}
+		valid = append(valid, t)
+	}
+	switch {
+	case len(problems) > 0:
+		message := strings.Join(problems, "; ")
+		logger.Info("Pipeline params are invalid", "reason", reason, "message", message)
+		meta.SetStatusCondition(&repo.Status.Conditions, metav1.Condition{
+			Type:    pollingv1.PipelineValidCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: message,
+		})
+	case checked:
+		meta.SetStatusCondition(&repo.Status.Conditions, metav1.Condition{
+			Type:    pollingv1.PipelineValidCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  pollingv1.PipelineValidReason,
+			Message: "The params match the params declared by the Pipeline",
+		})
+	case meta.FindStatusCondition(repo.Status.Conditions, pollingv1.PipelineValidCondition) != nil:
+		// RemoveStatusCondition panics if there are no conditions.
+		meta.RemoveStatusCondition(&repo.Status.Conditions, pollingv1.PipelineValidCondition)
+	}
+	return valid, nil
+}
+
+// pipelineSpecFor returns the spec of the Pipeline that the target executes,
+// or nil if the Pipeline can't be checked.
+func (r *ReconcileRepository) pipelineSpecFor(ctx context.Context, t runTarget) (*pipelinev1.PipelineSpec, error) {
+	if t.opts.Kind == pipelines.TaskRunKind {
+		return nil, nil
+	}
+	if t.opts.Name == "" {
+		return t.opts.PipelineSpec, nil
+	}
+	if t.opts.Bundle != "" || t.opts.Resolver != "" {
+		return nil, nil
+	}
+	p := &pipelinev1.Pipeline{}
+	if err := r.apiReader.Get(ctx, types.NamespacedName{Name: t.opts.Name, Namespace: t.ns}, p); err != nil {
+		return nil, err
+	}
+	return &p.Spec, nil
+}
+
+// invalidParams describes the params that are missing, unknown, or the wrong
+// type, for the declared params.
+func invalidParams(declared []pipelinev1.ParamSpec, params []pipelinev1.Param) []string {
+	supplied := map[string]pipelinev1.Param{}
+	for _, p := range params {
+		supplied[p.Name] = p
+	}
+	known := map[string]bool{}
+	problems := []string{}
+	for _, d := range declared {
+		known[d.Name] = true
+		p, ok := supplied[d.Name]
+		if !ok {
+			if d.Default == nil {
+				problems = append(problems, fmt.Sprintf("missing param %q", d.Name))
+			}
+			continue
+		}
+		want := d.Type
+		if want == "" {
+			want = pipelinev1.ParamTypeString
+		}
+		if p.Value.Type != want {
+			problems = append(problems, fmt.Sprintf("param %q is %s, but the Pipeline declares %s", d.Name, describeType(p.Value.Type), describeType(want)))
+		}
+	}
+	for _, p := range params {
+		if !known[p.Name] {
+			problems = append(problems, fmt.Sprintf("unknown param %q", p.Name))
+		}
+	}
+	return problems
+}
+
+// suppliedParams returns the params for the run, the params from the
+// PipelineRunTemplate are overridden by the params for the Pipeline.
+//
+// The object params are included with the object type, and no value.
+func suppliedParams(opts pipelines.RunOptions) []pipelinev1.Param {
+	supplied := append([]pipelinev1.Param{}, opts.Params...)
+	for _, o := range opts.ObjectParams {
+		supplied = append(supplied, pipelinev1.Param{Name: o.Name, Value: pipelinev1.ArrayOrString{Type: objectParamType}})
+	}
+	if opts.Template == nil {
+		return supplied
+	}
+	names := map[string]bool{}
+	for _, p := range supplied {
+		names[p.Name] = true
+	}
+	params := []pipelinev1.Param{}
+	for _, p := range opts.Template.Spec.Params {
+		if !names[p.Name] {
+			params = append(params, p)
+		}
+	}
+	return append(params, supplied...)
+}
+
+// objectParamType is the Tekton type of object params, this isn't declared by
+// the vendored Tekton types.
+const objectParamType = pipelinev1.ParamType(pollingv1.ObjectParamType)
+
+func describeType(t pipelinev1.ParamType) string {
+	switch t {
+	case pipelinev1.ParamTypeArray:
+		return "an array"
+	case objectParamType:
+		return "an object"
+	}
+	return "a string"
+}
+
+func describePipeline(t runTarget) string {
+	if t.opts.Name == "" {
+		return "pipelineSpec"
+	}
+	return fmt.Sprintf("Pipeline %s/%s", t.ns, t.opts.Name)
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+func TestInvalidParams(t *testing.T) {
+	stringParam := func(n string) pipelinev1.Param {
+		return pipelinev1.Param{Name: n, Value: *pipelinev1.NewArrayOrString("value")}
+	}
+	arrayParam := func(n string) pipelinev1.Param {
+		return pipelinev1.Param{Name: n, Value: *pipelinev1.NewArrayOrString("one", "two")}
+	}
+	objectParam := func(n string) pipelinev1.Param {
+		return pipelinev1.Param{Name: n, Value: pipelinev1.ArrayOrString{Type: objectParamType}}
+	}
+	paramsTests := []struct {
+		name     string
+		declared []pipelinev1.ParamSpec
+		params   []pipelinev1.Param
+		want     []string
+	}{
+		{
+			"matching params",
+			[]pipelinev1.ParamSpec{{Name: "sha"}, {Name: "files", Type: pipelinev1.ParamTypeArray}},
+			[]pipelinev1.Param{stringParam("sha"), arrayParam("files")},
+			[]string{},
+		},
+		{
+			"missing param with a default",
+			[]pipelinev1.ParamSpec{{Name: "sha", Default: pipelinev1.NewArrayOrString("main")}},
+			nil,
+			[]string{},
+		},
+		{
+			"missing param",
+			[]pipelinev1.ParamSpec{{Name: "sha"}, {Name: "url"}},
+			[]pipelinev1.Param{stringParam("url")},
+			[]string{`missing param "sha"`},
+		},
+		{
+			"unknown param",
+			[]pipelinev1.ParamSpec{{Name: "sha"}},
+			[]pipelinev1.Param{stringParam("sha"), stringParam("branch")},
+			[]string{`unknown param "branch"`},
+		},
+		{
+			"array for a string",
+			[]pipelinev1.ParamSpec{{Name: "sha", Type: pipelinev1.ParamTypeString}},
+			[]pipelinev1.Param{arrayParam("sha")},
+			[]string{`param "sha" is an array, but the Pipeline declares a string`},
+		},
+		{
+			"string for an array",
+			[]pipelinev1.ParamSpec{{Name: "files", Type: pipelinev1.ParamTypeArray}},
+			[]pipelinev1.Param{stringParam("files")},
+			[]string{`param "files" is a string, but the Pipeline declares an array`},
+		},
+		{
+			"matching object",
+			[]pipelinev1.ParamSpec{{Name: "source", Type: objectParamType}},
+			[]pipelinev1.Param{objectParam("source")},
+			[]string{},
+		},
+		{
+			"object for a string",
+			[]pipelinev1.ParamSpec{{Name: "sha"}},
+			[]pipelinev1.Param{objectParam("sha")},
+			[]string{`param "sha" is an object, but the Pipeline declares a string`},
+		},
+	}
+
+	for _, tt := range paramsTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, invalidParams(tt.declared, tt.params)); diff != "" {
+				t.Fatalf("invalidParams() failed:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReconcileRepositoryWithInvalidParams(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline.Name = "strict-pipeline"
+	})
+	cl, r := makeReconciler(t, repo, repo,
+		makeTestPipeline("strict-pipeline", testRepositoryNamespace, "one", "sha"))
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), req.NamespacedName, loaded))
+	want := []metav1.Condition{
+		{
+			Type:    pollingv1.PipelineValidCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  pollingv1.InvalidParamsReason,
+			Message: `Pipeline test-repository-ns/strict-pipeline: missing param "sha", unknown param "two"`,
+		},
+	}
+	if diff := cmp.Diff(want, loaded.Status.Conditions, ignoreTransitionTime); diff != "" {
+		t.Fatalf("incorrect conditions:\n%s", diff)
+	}
+	if loaded.Status.PendingTrigger != nil {
+		t.Fatalf("got PendingTrigger %#v, want it to be cleared", loaded.Status.PendingTrigger)
+	}
+}
+
+func TestReconcileRepositoryWithObjectParams(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline.Name = "object-pipeline"
+		r.Spec.Pipeline.Params = append(r.Spec.Pipeline.Params, pollingv1.Param{
+			Name:       "source",
+			Expression: "{'url': repoURL, 'revision': commit.id}",
+			Type:       pollingv1.ObjectParamType,
+		})
+	})
+	pipeline := makeTestPipeline("object-pipeline", testRepositoryNamespace, "one", "two")
+	pipeline.Spec.Params = append(pipeline.Spec.Params, pipelinev1.ParamSpec{Name: "source", Type: objectParamType})
+	_, r := makeReconciler(t, repo, repo, pipeline)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	opts := r.runner.(*pipelines.MockRunner).RunOptions("object-pipeline", testRepositoryNamespace)
+	want := []pipelines.ObjectParam{
+		{Name: "source", Value: map[string]string{"url": testRepoURL, "revision": testRef}},
+	}
+	if diff := cmp.Diff(want, opts.ObjectParams); diff != "" {
+		t.Fatalf("incorrect object params:\n%s", diff)
+	}
+	if diff := cmp.Diff(makeTestParams(map[string]string{"one": testRepoURL, "two": testRef}), opts.Params); diff != "" {
+		t.Fatalf("incorrect params:\n%s", diff)
+	}
+}
+
+func TestReconcileRepositoryWithMissingPipeline(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline.Name = "missing-pipeline"
+	})
+	cl, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), req.NamespacedName, loaded))
+	want := []metav1.Condition{
+		{
+			Type:    pollingv1.PipelineValidCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  pollingv1.PipelineNotFoundReason,
+			Message: "Pipeline test-repository-ns/missing-pipeline not found",
+		},
+	}
+	if diff := cmp.Diff(want, loaded.Status.Conditions, ignoreTransitionTime); diff != "" {
+		t.Fatalf("incorrect conditions:\n%s", diff)
+	}
+}
+
+func TestReconcileRepositoryWithTaskHasNoPipelineCondition(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline = pollingv1.PipelineRef{}
+		r.Spec.Task = &pollingv1.TaskRef{Name: testTaskName}
+		r.Status.Conditions = []metav1.Condition{
+			{Type: pollingv1.PipelineValidCondition, Status: metav1.ConditionFalse, Reason: pollingv1.PipelineNotFoundReason},
+		}
+	})
+	cl, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), req.NamespacedName, loaded))
+	if len(loaded.Status.Conditions) != 0 {
+		t.Fatalf("got conditions %#v, want none", loaded.Status.Conditions)
+	}
+}
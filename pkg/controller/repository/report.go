@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/git"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+type statusReporterFactory func(repo *pollingv1.Repository, endpoint, authToken string) git.StatusReporter
+
+// reportStatus reports the status of the run as a commit status, if the
+// Repository is configured to report statuses, and the state of the run has
+// changed since it was last reported.
+//
+// The reported state is recorded on the run, rather than in the Repository's
+// status, so that runs that are superseded by a newer change before they
+// complete are still reported.
+func (r *ReconcileRunStatus) reportStatus(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository, run pipelines.RunObject, updated *pollingv1.RunStatus) error {
+	if repo.Spec.ReportStatus == nil || updated.SHA == "" {
+		return nil
+	}
+	state, ok := commitState(updated.Succeeded)
+	if !ok {
+		return nil
+	}
+	if run.GetAnnotations()[pollingv1.ReportedStatusAnnotation] == string(state) {
+		return nil
+	}
+	status, err := makeCommitStatus(repo, state, updated)
+	if err != nil {
+		logger.Error(err, "failed to make the commit status")
+		return err
+	}
+	repoName, endpoint, err := repoFromURL(repo.Spec.URL)
+	if err != nil {
+		logger.Error(err, "Parsing the repo from the URL failed", "repoURL", repo.Spec.URL)
+		return err
+	}
+	authToken, err := authTokenForRepo(ctx, r.secretGetter, logger, repo.Namespace, repo)
+	if err != nil {
+		return err
+	}
+	reporter := r.reporterFactory(repo, endpoint, authToken)
+	if reporter == nil {
+		return nil
+	}
+	if err := reporter.ReportStatus(repoName, updated.SHA, status); err != nil {
+		logger.Error(err, "failed to report the commit status", "sha", updated.SHA, "state", state)
+		return err
+	}
+	logger.Info("Reported commit status", "sha", updated.SHA, "state", state)
+	if err := r.annotateRun(ctx, run, map[string]string{pollingv1.ReportedStatusAnnotation: string(state)}); err != nil {
+		logger.Error(err, "failed to record the reported commit status", "sha", updated.SHA, "state", state)
+		return err
+	}
+	return nil
+}
+
+// commitState returns the commit status state for the status of a run's
+// Succeeded condition, runs that haven't started don't have a state.
+func commitState(s corev1.ConditionStatus) (git.StatusState, bool) {
+	switch s {
+	case corev1.ConditionUnknown:
+		return git.StatusPending, true
+	case corev1.ConditionTrue:
+		return git.StatusSuccess, true
+	case corev1.ConditionFalse:
+		return git.StatusFailure, true
+	}
+	return "", false
+}
+
+func makeCommitStatus(repo *pollingv1.Repository, state git.StatusState, run *pollingv1.RunStatus) (git.CommitStatus, error) {
+	cfg := repo.Spec.ReportStatus
+	targetURL, err := expandURL(cfg.TargetURL, run)
+	if err != nil {
+		return git.CommitStatus{}, err
+	}
+	return git.CommitStatus{
+		State:       state,
+		Context:     reportedName(cfg.GetContext(), repo, run),
+		Description: runDescription(state, run),
+		TargetURL:   targetURL,
+	}, nil
+}
+
+// reportedName qualifies the name with the Pipeline that the run was created
+// for, and the namespace of runs for a NamespaceSelector, so that each of the
+// runs for a change are reported separately.
+func reportedName(name string, repo *pollingv1.Repository, run *pollingv1.RunStatus) string {
+	if run.Pipeline != "" {
+		name = name + "/" + run.Pipeline
+	}
+	if pipelineRefFor(repo, run.Pipeline).NamespaceSelector != nil {
+		name = name + "/" + run.Namespace
+	}
+	return name
+}
+
+// runDescription describes the state of the run.
+func runDescription(state git.StatusState, run *pollingv1.RunStatus) string {
+	switch state {
+	case git.StatusSuccess:
+		return fmt.Sprintf("The %s succeeded", run.Kind)
+	case git.StatusFailure:
+		if run.Reason != "" {
+			return fmt.Sprintf("The %s failed: %s", run.Kind, run.Reason)
+		}
+		return fmt.Sprintf("The %s failed", run.Kind)
+	}
+	return fmt.Sprintf("The %s is running", run.Kind)
+}
+
+// expandURL executes the URL template with the run.
+func expandURL(s string, run *pollingv1.RunStatus) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	tmpl, err := template.New("url").Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the URL template: %w", err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, run); err != nil {
+		return "", fmt.Errorf("failed to execute the URL template: %w", err)
+	}
+	return b.String(), nil
+}
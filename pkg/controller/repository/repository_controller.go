@@ -5,46 +5,88 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/blackout"
 	"github.com/bigkevmcd/tekton-polling-operator/pkg/cel"
 	"github.com/bigkevmcd/tekton-polling-operator/pkg/git"
 	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
 	"github.com/bigkevmcd/tekton-polling-operator/pkg/secrets"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/triggers"
 	"github.com/go-logr/logr"
 	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
+// Options configures the Repository Controller.
+type Options struct {
+	// Jitter is the default maximum delay added to the frequency of each
+	// Repository, this can be overridden by the Repository.
+	Jitter time.Duration
+	// APIVersion is the default Tekton API version for created runs, this can
+	// be overridden by the Repository.
+	APIVersion pipelines.APIVersion
+	// DryRun renders the runs for all Repositories without creating them,
+	// regardless of the Repository's DryRun.
+	DryRun bool
+}
+
 // Add creates a new Repository Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
-func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+func Add(mgr manager.Manager, opts Options) error {
+	if err := add(mgr, newReconciler(mgr, opts)); err != nil {
+		return err
+	}
+	for _, kind := range []pipelines.RunKind{pipelines.PipelineRunKind, pipelines.TaskRunKind} {
+		if err := addRunStatus(mgr, kind); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type commitPollerFactory func(repo *pollingv1.Repository, endpoint, authToken string) git.CommitPoller
 
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+func newReconciler(mgr manager.Manager, opts Options) reconcile.Reconciler {
 	return &ReconcileRepository{
-		client: mgr.GetClient(),
-		scheme: mgr.GetScheme(),
+		client:    mgr.GetClient(),
+		apiReader: mgr.GetAPIReader(),
+		scheme:    mgr.GetScheme(),
 		pollerFactory: func(repo *pollingv1.Repository, endpoint, token string) git.CommitPoller {
 			return makeCommitPoller(repo, endpoint, token)
 		},
-		pipelineRunner: pipelines.NewRunner(mgr.GetClient()),
+		runner:         pipelines.NewRunner(mgr.GetClient()),
+		templateRunner: triggers.NewRunner(mgr.GetClient()),
 		secretGetter:   secrets.New(mgr.GetClient()),
 		log:            logf.Log.WithName("controller_repository"),
+		clock:          clock.RealClock{},
+		jitter:         opts.Jitter,
+		apiVersion:     opts.APIVersion,
+		dryRun:         opts.DryRun,
+		seen:           make(map[types.NamespacedName]bool),
+		triggerID:      func() string { return rand.String(10) },
 	}
 }
 
@@ -53,25 +95,53 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	if err != nil {
 		return err
 	}
-	err = c.Watch(&source.Kind{Type: &pollingv1.Repository{}}, &handler.EnqueueRequestForObject{})
+	err = c.Watch(&source.Kind{Type: &pollingv1.Repository{}}, &handler.EnqueueRequestForObject{}, repositoryChanged)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// repositoryChanged filters out updates to Repositories that only change the
+// status, which is updated when runs change, so that these don't trigger
+// polls, the Repository is requeued for the next poll when it's reconciled.
+var repositoryChanged = predicate.Or(predicate.GenerationChangedPredicate{}, triggerAnnotationChanged)
+
+// triggerAnnotationChanged accepts updates that change the TriggerAnnotation,
+// which doesn't change the generation of the Repository.
+var triggerAnnotationChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.MetaOld.GetAnnotations()[pollingv1.TriggerAnnotation] != e.MetaNew.GetAnnotations()[pollingv1.TriggerAnnotation]
+	},
+}
+
 // ReconcileRepository reconciles a Repository object.
 type ReconcileRepository struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
-	scheme *runtime.Scheme
+	// The apiReader reads directly from the apiserver, this is used to list
+	// namespaces, which aren't cached.
+	apiReader client.Reader
+	scheme    *runtime.Scheme
 	// The poller polls the endpoint for the repo.
 	pollerFactory commitPollerFactory
-	// The pipelineRunner executes the named pipeline with appropriate params.
-	pipelineRunner pipelines.PipelineRunner
+	// The runner executes the pipeline or task with appropriate params.
+	runner pipelines.Runner
+	// The templateRunner creates the resources from TriggerTemplates.
+	templateRunner triggers.TemplateRunner
 	secretGetter   secrets.SecretGetter
 	log            logr.Logger
+	clock          clock.Clock
+	jitter         time.Duration
+	apiVersion     pipelines.APIVersion
+	dryRun         bool
+	// seen records the Repositories that have been reconciled since the
+	// controller started.
+	seenMu sync.Mutex
+	seen   map[types.NamespacedName]bool
+	// triggerID generates the IDs for PendingTriggers.
+	triggerID func() string
 }
 
 // Reconcile reads that state of the cluster for a Repository object and makes changes based on the state read
@@ -93,20 +163,68 @@ func (r *ReconcileRepository) Reconcile(req reconcile.Request) (reconcile.Result
 		return reconcile.Result{}, err
 	}
 
+	if err := repo.Validate(); err != nil {
+		reqLogger.Error(err, "Repository is invalid")
+		repo.Status.LastError = err.Error()
+		if err := r.client.Status().Update(ctx, repo); err != nil {
+			reqLogger.Error(err, "unable to update Repository status")
+			return reconcile.Result{}, err
+		}
+		// The Repository will be reconciled again when it's updated.
+		return reconcile.Result{}, nil
+	}
+
+	if repo.Generation != repo.Status.ObservedGeneration {
+		valid := compileExpressions(reqLogger, repo)
+		if err := r.client.Status().Update(ctx, repo); err != nil {
+			reqLogger.Error(err, "unable to update Repository status")
+			return reconcile.Result{}, err
+		}
+		if !valid {
+			return reconcile.Result{}, nil
+		}
+	} else if meta.IsStatusConditionFalse(repo.Status.Conditions, pollingv1.ExpressionsValidCondition) {
+		reqLogger.Info("Repository expressions failed to compile, not polling")
+		return reconcile.Result{}, nil
+	}
+
+	if delay := r.startupDelay(repo); delay > 0 {
+		reqLogger.Info("Delaying first poll", "after", delay)
+		return reconcile.Result{RequeueAfter: delay}, nil
+	}
+
 	repoName, endpoint, err := repoFromURL(repo.Spec.URL)
 	if err != nil {
 		reqLogger.Error(err, "Parsing the repo from the URL failed", "repoURL", repo.Spec.URL)
 		return reconcile.Result{}, err
 	}
 
-	authToken, err := r.authTokenForRepo(ctx, reqLogger, req.Namespace, repo)
+	authToken, err := authTokenForRepo(ctx, r.secretGetter, reqLogger, req.Namespace, repo)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	now := r.clock.Now()
+	inBlackout, blackoutEnd, err := blackout.Active(repo.Spec.BlackoutWindows, now)
 	if err != nil {
+		reqLogger.Error(err, "Checking the blackout windows failed")
 		return reconcile.Result{}, err
 	}
 
 	repo.Status.PollStatus.Ref = repo.Spec.Ref
+	pollStatus := repo.Status.PollStatus
+	trigger := repo.RequestedTrigger()
+	deferred := repo.Status.DeferredSHA != "" && !inBlackout
+	// A pending trigger means that creating the run for a change failed, so
+	// it's retried.
+	pending := repo.Status.PendingTrigger != nil && !inBlackout
+	if trigger != "" || deferred || pending {
+		// Clearing the ETag ensures that the commit is returned even if it's
+		// unchanged.
+		pollStatus.ETag = ""
+	}
 	// TODO: handle pollerFactory returning nil/error
-	newStatus, commit, err := r.pollerFactory(repo, endpoint, authToken).Poll(repoName, repo.Status.PollStatus)
+	newStatus, commit, err := r.pollerFactory(repo, endpoint, authToken).Poll(repoName, pollStatus)
 	if err != nil {
 		repo.Status.LastError = err.Error()
 		reqLogger.Error(err, "Repository poll failed")
@@ -116,46 +234,224 @@ func (r *ReconcileRepository) Reconcile(req reconcile.Request) (reconcile.Result
 		return reconcile.Result{}, err
 	}
 
+	hadError := repo.Status.LastError != ""
 	repo.Status.LastError = ""
 	changed := !newStatus.Equal(repo.Status.PollStatus)
-	if repo.Status.LastError != "" {
-		repo.Status.LastError = ""
-		changed = true
-	}
-	if !changed {
-		reqLogger.Info("Poll Status unchanged, requeueing next check", "frequency", repo.GetFrequency())
-		return reconcile.Result{RequeueAfter: repo.GetFrequency()}, nil
+	if !changed && trigger == "" && !deferred && !pending {
+		// The status is only updated when the poll is unchanged if a previous
+		// error needs to be cleared.
+		if hadError {
+			if err := r.client.Status().Update(ctx, repo); err != nil {
+				reqLogger.Error(err, "unable to update Repository status")
+				return reconcile.Result{}, err
+			}
+		}
+		requeue := r.nextPoll(repo)
+		if inBlackout {
+			requeue = minDuration(requeue, blackoutEnd.Sub(now))
+		}
+		reqLogger.Info("Poll Status unchanged, requeueing next check", "after", requeue)
+		return reconcile.Result{RequeueAfter: requeue}, nil
 	}
 
-	reqLogger.Info("Poll Status changed", "status", newStatus)
+	if changed {
+		reqLogger.Info("Poll Status changed", "status", newStatus)
+	}
+	if trigger != "" {
+		reqLogger.Info("PipelineRun triggered by annotation", "trigger", trigger)
+		repo.Status.LastTrigger = trigger
+	}
+	previousSHA := repo.Status.PollStatus.SHA
 	repo.Status.PollStatus = newStatus
-	if err := r.client.Status().Update(ctx, repo); err != nil {
-		reqLogger.Error(err, "unable to update Repository status")
+	if inBlackout {
+		return r.handleBlackout(ctx, reqLogger, repo, blackoutEnd.Sub(now))
+	}
+	celctx, err := cel.New(celInput(repo, commit, previousSHA, now))
+	if err != nil {
+		reqLogger.Error(err, "failed to create the expression context")
 		return reconcile.Result{}, err
 	}
-	runNS := repo.Spec.Pipeline.Namespace
-	if runNS == "" {
-		runNS = req.Namespace
+	switch {
+	case r.isDryRun(repo):
+		err = r.renderRuns(ctx, reqLogger, repo, celctx)
+	case repo.Spec.TriggerTemplate != nil:
+		err = r.runTriggerTemplate(ctx, reqLogger, repo, celctx)
+	default:
+		err = r.createRun(ctx, reqLogger, repo, celctx)
 	}
-	serviceAccountName := repo.Spec.Pipeline.ServiceAccountName
-	workspaces := repo.Spec.Pipeline.Workspaces
-
-	params, err := makeParams(commit, repo.Spec)
 	if err != nil {
-		reqLogger.Error(err, "failed to parse the parameters")
 		return reconcile.Result{}, err
 	}
-	pr, err := r.pipelineRunner.Run(ctx, repo.Spec.Pipeline.Name, runNS, serviceAccountName, params, repo.Spec.Pipeline.Resources, workspaces)
+	requeue := r.nextPoll(repo)
+	reqLogger.Info("Requeueing next check", "after", requeue)
+	return reconcile.Result{RequeueAfter: requeue}, nil
+}
+
+// isDryRun returns true if runs should be rendered for the Repository without
+// creating them, or deleting any existing runs.
+func (r *ReconcileRepository) isDryRun(repo *pollingv1.Repository) bool {
+	return r.dryRun || repo.Spec.DryRun
+}
+
+// createRun creates a PipelineRun or TaskRun for the Repository's pipeline or
+// task, or a PipelineRun for each of the Repository's Pipelines.
+//
+// The PendingTrigger is recorded in the status before the runs are created,
+// and cleared once they have been created, if creating a run fails, or the
+// status can't be updated, the runs are looked up by the trigger ID on the
+// next reconciliation, so that each is only created once.
+func (r *ReconcileRepository) createRun(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository, celctx *cel.Context) error {
+	targets, err := r.runTargets(ctx, celctx, repo)
+	if err != nil {
+		logger.Error(err, "failed to parse the parameters")
+		return err
+	}
+	targets, err = r.validateTargets(ctx, logger, repo, targets)
 	if err != nil {
-		reqLogger.Error(err, "failed to create a PipelineRun", "pipelineName", repo.Spec.Pipeline.Name)
+		return err
+	}
+	pending := r.pendingTrigger(repo)
+	for i := range targets {
+		targets[i].opts.APIVersion = r.runAPIVersion(repo, targets[i].opts)
+		targets[i].opts.Labels[pollingv1.TriggerIDLabel] = pending.ID
+	}
+	created := []pollingv1.RunStatus{}
+	if pending == repo.Status.PendingTrigger {
+		remaining := []runTarget{}
+		for _, t := range targets {
+			run, err := r.findPendingRun(ctx, t.ns, t.opts)
+			if err != nil {
+				logger.Error(err, "failed to find the run for the pending trigger", "sha", pending.SHA, "id", pending.ID)
+				return err
+			}
+			if run == nil {
+				remaining = append(remaining, t)
+				continue
+			}
+			logger.Info("Run already created for pending trigger", "sha", pending.SHA, "id", pending.ID, "name", run.GetName())
+			created = append(created, createdRun(t.opts, run, pending.SHA))
+		}
+		targets = remaining
+	}
+	// If some of the runs were already created, the concurrency policy was
+	// applied before they were created.
+	create := true
+	if len(created) == 0 {
+		create, err = r.applyConcurrencyPolicy(ctx, logger, repo, targets)
+		if err != nil {
+			return err
+		}
+	}
+	repo.Status.PendingTrigger = nil
+	if create {
+		repo.Status.DeferredSHA = ""
+		repo.Status.PendingTrigger = pending
+	}
+	if err := r.updateStatus(ctx, logger, repo); err != nil {
+		return err
+	}
+	if !create {
+		return nil
+	}
+	for _, t := range targets {
+		run, err := r.runner.Run(ctx, t.ns, t.opts)
+		if err != nil {
+			logger.Error(err, "failed to create a run", "kind", t.opts.Kind, "name", t.opts.Name)
+			return err
+		}
+		logger.Info("Run created", "kind", t.opts.Kind, "name", run.GetName())
+		created = append(created, createdRun(t.opts, run, pending.SHA))
+	}
+	repo.Status.PendingTrigger = nil
+	recordCreatedRuns(repo, created)
+	if err := r.updateStatus(ctx, logger, repo); err != nil {
+		return err
+	}
+	if len(created) > 0 {
+		r.pruneRuns(ctx, logger, repo)
+	}
+	return nil
+}
+
+// recordCreatedRuns records the runs created for a change in the status, if
+// no runs were created for the change, because none of the Repository's
+// Pipelines matched, or no namespaces were selected, the previous runs are
+// kept.
+func recordCreatedRuns(repo *pollingv1.Repository, created []pollingv1.RunStatus) {
+	if len(created) == 0 {
+		return
+	}
+	if !recordsLastRuns(repo) {
+		repo.Status.LastRun = &created[0]
+		return
+	}
+	repo.Status.LastRuns = created
+}
+
+// createdRun returns the RunStatus for a newly created run, the outcome of
+// the run is recorded as it progresses.
+func createdRun(opts pipelines.RunOptions, run metav1.Object, sha string) pollingv1.RunStatus {
+	return pollingv1.RunStatus{
+		Kind:      string(opts.Kind),
+		Name:      run.GetName(),
+		Namespace: run.GetNamespace(),
+		SHA:       sha,
+		Pipeline:  opts.Labels[pollingv1.PipelineLabel],
+	}
+}
+
+// pendingTrigger returns the PendingTrigger for the polled SHA, if there's no
+// outstanding trigger for the SHA, a new one is returned.
+func (r *ReconcileRepository) pendingTrigger(repo *pollingv1.Repository) *pollingv1.PendingTrigger {
+	sha := repo.Status.PollStatus.SHA
+	if p := repo.Status.PendingTrigger; p != nil && p.SHA == sha {
+		return p
+	}
+	return &pollingv1.PendingTrigger{SHA: sha, ID: r.triggerID()}
+}
+
+// findPendingRun returns the run that has already been created with the
+// trigger ID in the options, or nil if there's no run.
+func (r *ReconcileRepository) findPendingRun(ctx context.Context, ns string, opts pipelines.RunOptions) (*unstructured.Unstructured, error) {
+	selector := targetSelector(ns, opts)
+	selector.labels[pollingv1.TriggerIDLabel] = opts.Labels[pollingv1.TriggerIDLabel]
+	runs, err := r.runner.ListRuns(ctx, selector.ns, selector.kind, opts.APIVersion, selector.labels)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return runs[0], nil
+}
+
+func (r *ReconcileRepository) updateStatus(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository) error {
+	if err := r.client.Status().Update(ctx, repo); err != nil {
+		logger.Error(err, "unable to update Repository status")
+		return err
+	}
+	return nil
+}
+
+// handleBlackout records the detected change, and requeues the next check, no
+// later than the end of the blackout window.
+func (r *ReconcileRepository) handleBlackout(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository, remaining time.Duration) (reconcile.Result, error) {
+	if repo.Spec.BlackoutPolicy == pollingv1.BlackoutDrop {
+		logger.Info("Blackout window active, dropping change", "sha", repo.Status.PollStatus.SHA)
+		repo.Status.DeferredSHA = ""
+	} else {
+		logger.Info("Blackout window active, deferring change", "sha", repo.Status.PollStatus.SHA)
+		repo.Status.DeferredSHA = repo.Status.PollStatus.SHA
+	}
+	if err := r.client.Status().Update(ctx, repo); err != nil {
+		logger.Error(err, "unable to update Repository status")
 		return reconcile.Result{}, err
 	}
-	reqLogger.Info("PipelineRun created", "name", pr.ObjectMeta.Name)
-	reqLogger.Info("Requeueing next check", "frequency", repo.GetFrequency())
-	return reconcile.Result{RequeueAfter: repo.GetFrequency()}, nil
+	// Requeueing at the end of the window ensures that deferred changes are
+	// handled promptly.
+	requeue := minDuration(r.nextPoll(repo), remaining)
+	logger.Info("Requeueing next check", "after", requeue)
+	return reconcile.Result{RequeueAfter: requeue}, nil
 }
 
-func (r *ReconcileRepository) authTokenForRepo(ctx context.Context, logger logr.Logger, namespace string, repo *pollingv1.Repository) (string, error) {
+func authTokenForRepo(ctx context.Context, getter secrets.SecretGetter, logger logr.Logger, namespace string, repo *pollingv1.Repository) (string, error) {
 	if repo.Spec.Auth == nil {
 		return "", nil
 	}
@@ -163,7 +459,7 @@ func (r *ReconcileRepository) authTokenForRepo(ctx context.Context, logger logr.
 	if repo.Spec.Auth.Key != "" {
 		key = repo.Spec.Auth.Key
 	}
-	authToken, err := r.secretGetter.SecretToken(ctx, types.NamespacedName{Name: repo.Spec.Auth.Name, Namespace: namespace}, key)
+	authToken, err := getter.SecretToken(ctx, types.NamespacedName{Name: repo.Spec.Auth.Name, Namespace: namespace}, key)
 	if err != nil {
 		logger.Error(err, "Getting the auth token failed", "name", repo.Spec.Auth.Name, "namespace", namespace, "key", key)
 		return "", err
@@ -171,13 +467,174 @@ func (r *ReconcileRepository) authTokenForRepo(ctx context.Context, logger logr.
 	return authToken, nil
 }
 
-func makeParams(commit git.Commit, spec pollingv1.RepositorySpec) ([]pipelinev1.Param, error) {
-	celctx, err := cel.New(spec.URL, commit)
+func (r *ReconcileRepository) apiVersionFor(repo *pollingv1.Repository) pipelines.APIVersion {
+	if repo.Spec.TektonAPIVersion != "" {
+		return pipelines.APIVersion(repo.Spec.TektonAPIVersion)
+	}
+	return r.apiVersion
+}
+
+// runAPIVersion returns the API version for a run, v1 doesn't support
+// resources, so runs with resources fall back to v1beta1 when v1 is the
+// operator's default, rather than failing, Repositories can't explicitly
+// configure v1 with resources.
+func (r *ReconcileRepository) runAPIVersion(repo *pollingv1.Repository, opts pipelines.RunOptions) pipelines.APIVersion {
+	v := r.apiVersionFor(repo)
+	if v == pipelines.V1 && repo.Spec.TektonAPIVersion == "" && len(opts.Resources) > 0 {
+		return pipelines.V1Beta1
+	}
+	return v
+}
+
+// makeRunOptions returns the namespace to create the run in, and the options
+// for executing the Repository's pipeline or task.
+func makeRunOptions(celctx *cel.Context, repo *pollingv1.Repository) (string, pipelines.RunOptions, error) {
+	var paramSpecs []pollingv1.Param
+	var opts pipelines.RunOptions
+	if repo.RunsTask() {
+		task := pollingv1.TaskRef{}
+		if repo.Spec.Task != nil {
+			task = *repo.Spec.Task
+		}
+		paramSpecs = task.Params
+		opts = pipelines.RunOptions{
+			Kind:               pipelines.TaskRunKind,
+			Name:               task.Name,
+			ServiceAccountName: task.ServiceAccountName,
+			Workspaces:         task.Workspaces,
+		}
+		if repo.Spec.TaskSpec != nil {
+			opts.TaskSpec = &repo.Spec.TaskSpec.TaskSpec
+		}
+	} else {
+		paramSpecs = repo.Spec.Pipeline.Params
+		opts = pipelines.RunOptions{
+			Kind:               pipelines.PipelineRunKind,
+			Name:               repo.Spec.Pipeline.Name,
+			ServiceAccountName: repo.Spec.Pipeline.ServiceAccountName,
+			Resources:          repo.Spec.Pipeline.Resources,
+			Workspaces:         repo.Spec.Pipeline.Workspaces,
+			Template:           repo.Spec.PipelineRunTemplate,
+			Bundle:             repo.Spec.Pipeline.Bundle,
+			Resolver:           repo.Spec.Pipeline.Resolver,
+			ResolverParams:     repo.Spec.Pipeline.ResolverParams,
+		}
+		if repo.Spec.PipelineSpec != nil {
+			opts.PipelineSpec = &repo.Spec.PipelineSpec.PipelineSpec
+		}
+	}
+	ns := runNamespace(repo)
+	opts.Labels = createdRunLabels(repo)
+	// Owner references can't cross namespaces, runs in other namespaces are
+	// only identified by their labels.
+	if ns == repo.Namespace {
+		opts.OwnerReferences = []metav1.OwnerReference{ownerReference(repo)}
+	}
+	params, err := makeParams(celctx, paramSpecs)
 	if err != nil {
-		return nil, err
+		return "", opts, err
+	}
+	opts.Params = params
+	opts.ObjectParams, err = makeObjectParams(celctx, paramSpecs)
+	if err != nil {
+		return "", opts, err
+	}
+	opts.Workspaces = runWorkspaces(repo, opts.Workspaces, params)
+	return ns, opts, nil
+}
+
+// runNamespace returns the namespace that runs are created in for the
+// Repository.
+func runNamespace(repo *pollingv1.Repository) string {
+	ns := repo.Spec.Pipeline.Namespace
+	if repo.RunsTask() {
+		ns = ""
+		if repo.Spec.Task != nil {
+			ns = repo.Spec.Task.Namespace
+		}
 	}
+	if ns == "" {
+		return repo.Namespace
+	}
+	return ns
+}
+
+// runLabels returns the labels that identify the runs created for the
+// Repository.
+func runLabels(repo *pollingv1.Repository) map[string]string {
+	return map[string]string{
+		pollingv1.RepositoryLabel:          repo.Name,
+		pollingv1.RepositoryNamespaceLabel: repo.Namespace,
+	}
+}
+
+// createdRunLabels returns the labels that are applied to runs created for
+// the polled change.
+func createdRunLabels(repo *pollingv1.Repository) map[string]string {
+	labels := runLabels(repo)
+	labels[pollingv1.SHALabel] = labelValue(repo.Status.PollStatus.SHA)
+	labels[pollingv1.RefLabel] = labelValue(repo.Spec.Ref)
+	return labels
+}
+
+// ownerReference returns a reference to the Repository for the runs created in
+// its namespace, so that they are deleted along with the Repository.
+//
+// BlockOwnerDeletion isn't set, as that requires permission to update the
+// finalizers of the Repository.
+func ownerReference(repo *pollingv1.Repository) metav1.OwnerReference {
+	isController := true
+	gvk := pollingv1.SchemeGroupVersion.WithKind("Repository")
+	return metav1.OwnerReference{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Name:       repo.Name,
+		UID:        repo.UID,
+		Controller: &isController,
+	}
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^-A-Za-z0-9_.]`)
+
+// labelValue converts s into a valid label value, characters that aren't
+// permitted in label values, for example the "/" in "feature/branch", are
+// replaced with "-", and it's truncated to the maximum length.
+func labelValue(s string) string {
+	v := invalidLabelChars.ReplaceAllString(s, "-")
+	if len(v) > validation.LabelValueMaxLength {
+		v = v[:validation.LabelValueMaxLength]
+	}
+	return strings.Trim(v, "-_.")
+}
+
+// celInput returns the data that the Repository's expressions are evaluated
+// against for the polled commit.
+func celInput(repo *pollingv1.Repository, commit git.Commit, previousSHA string, pollTime time.Time) cel.Input {
+	return cel.Input{
+		RepoURL:     repo.Spec.URL,
+		Commit:      commit,
+		Head:        git.NormaliseCommit(repo.Spec.Type, commit),
+		Ref:         repo.Spec.Ref,
+		PreviousSHA: previousSHA,
+		Provider:    string(repo.Spec.Type),
+		PollTime:    pollTime,
+		Repository: cel.Repository{
+			Name:        repo.Name,
+			Namespace:   repo.Namespace,
+			Labels:      repo.Labels,
+			Annotations: repo.Annotations,
+		},
+	}
+}
+
+// makeParams evaluates the string and array params, the object params are
+// evaluated by makeObjectParams.
+func makeParams(celctx *cel.Context, paramSpecs []pollingv1.Param) ([]pipelinev1.Param, error) {
 	params := []pipelinev1.Param{}
-	for _, v := range spec.Pipeline.Params {
+	for _, v := range paramSpecs {
+		if v.Type == pollingv1.ObjectParamType {
+			continue
+		}
 		val, err := celctx.EvaluateToParamValue(v.Expression)
 		if err != nil {
 			return nil, err
@@ -187,6 +644,22 @@ func makeParams(commit git.Commit, spec pollingv1.RepositorySpec) ([]pipelinev1.
 	return params, nil
 }
 
+// makeObjectParams evaluates the params with the object type.
+func makeObjectParams(celctx *cel.Context, paramSpecs []pollingv1.Param) ([]pipelines.ObjectParam, error) {
+	var objects []pipelines.ObjectParam
+	for _, v := range paramSpecs {
+		if v.Type != pollingv1.ObjectParamType {
+			continue
+		}
+		val, err := celctx.EvaluateToObject(v.Expression)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, pipelines.ObjectParam{Name: v.Name, Value: val})
+	}
+	return objects, nil
+}
+
 // TODO: create an HTTP client that has appropriate timeouts.
 // TODO: pass the logger through so that we can log out errors from this and
 // also the pipelinerun creator.
@@ -200,6 +673,16 @@ func makeCommitPoller(repo *pollingv1.Repository, endpoint, authToken string) gi
 	return nil
 }
 
+func makeStatusReporter(repo *pollingv1.Repository, endpoint, authToken string) git.StatusReporter {
+	switch repo.Spec.Type {
+	case pollingv1.GitHub:
+		return git.NewGitHubPoller(http.DefaultClient, endpoint, authToken)
+	case pollingv1.GitLab:
+		return git.NewGitLabPoller(http.DefaultClient, endpoint, authToken)
+	}
+	return nil
+}
+
 func repoFromURL(s string) (string, string, error) {
 	parsed, err := url.Parse(s)
 	if err != nil {
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,9 +12,11 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 
@@ -20,7 +24,9 @@ import (
 	"github.com/bigkevmcd/tekton-polling-operator/pkg/git"
 	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
 	"github.com/bigkevmcd/tekton-polling-operator/pkg/secrets"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/triggers"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 )
 
@@ -35,6 +41,9 @@ const (
 	testAuthToken           = "test-auth-token"
 	testCommitSHA           = "24317a55785cd98d6c9bf50a5204bc6be17e7316"
 	testCommitETag          = `W/"878f43039ad0553d0d3122d8bc171b01"`
+	testRepositoryUID       = "d1cf7a5e-4f3b-4b0e-9f40-5c1c3c9b6a2e"
+	testTriggerID           = "test-trigger"
+	testTaskName            = "test-task"
 	testPipelineName        = "test-pipeline"
 	testServiceAccountName  = "test-sa"
 )
@@ -49,6 +58,33 @@ var (
 			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "test-pvc"},
 		},
 	}
+	// Fridays at 18:00 until Monday at 08:00.
+	testBlackoutWindow = pollingv1.BlackoutWindow{
+		Start:    "0 18 * * 5",
+		Duration: metav1.Duration{Duration: time.Hour * 62},
+	}
+	testRunLabels = map[string]string{
+		pollingv1.RepositoryLabel:          testRepositoryName,
+		pollingv1.RepositoryNamespaceLabel: testRepositoryNamespace,
+	}
+	testCreatedRunLabels = map[string]string{
+		pollingv1.RepositoryLabel:          testRepositoryName,
+		pollingv1.RepositoryNamespaceLabel: testRepositoryNamespace,
+		pollingv1.SHALabel:                 testCommitSHA,
+		pollingv1.RefLabel:                 testRef,
+		pollingv1.TriggerIDLabel:           testTriggerID,
+	}
+	// The LastTransitionTime of conditions is set from the current time.
+	ignoreTransitionTime = cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime")
+	testOwnerReferences  = []metav1.OwnerReference{
+		{
+			APIVersion: "polling.tekton.dev/v1alpha1",
+			Kind:       "Repository",
+			Name:       testRepositoryName,
+			UID:        testRepositoryUID,
+			Controller: boolPtr(true),
+		},
+	}
 )
 
 func TestReconcileRepositoryWithEmptyPollState(t *testing.T) {
@@ -70,7 +106,7 @@ func TestReconcileRepositoryWithEmptyPollState(t *testing.T) {
 	loaded := &pollingv1.Repository{}
 	err = cl.Get(ctx, req.NamespacedName, loaded)
 	fatalIfError(t, err)
-	r.pipelineRunner.(*pipelines.MockRunner).AssertPipelineRun(
+	r.runner.(*pipelines.MockRunner).AssertPipelineRun(
 		testPipelineName, testRepositoryNamespace,
 		testServiceAccountName,
 		makeTestParams(map[string]string{"one": testRepoURL, "two": "main"}),
@@ -82,19 +118,60 @@ func TestReconcileRepositoryWithEmptyPollState(t *testing.T) {
 			SHA:  "24317a55785cd98d6c9bf50a5204bc6be17e7316",
 			ETag: `W/"878f43039ad0553d0d3122d8bc171b01"`,
 		},
+		LastRun: &pollingv1.RunStatus{
+			Kind:      "PipelineRun",
+			Name:      testPipelineName,
+			Namespace: testRepositoryNamespace,
+			SHA:       testCommitSHA,
+		},
+		Conditions: []metav1.Condition{
+			{
+				Type:    pollingv1.PipelineValidCondition,
+				Status:  metav1.ConditionTrue,
+				Reason:  pollingv1.PipelineValidReason,
+				Message: "The params match the params declared by the Pipeline",
+			},
+		},
 	}
-	if diff := cmp.Diff(wantStatus, loaded.Status); diff != "" {
+	if diff := cmp.Diff(wantStatus, loaded.Status, ignoreTransitionTime); diff != "" {
 		t.Fatalf("incorrect repository status:\n%s", diff)
 	}
 }
 
+func TestReconcileRepositoryWithExpressionContext(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	previousSHA := "6104942438c14ec7bd21c6cd5bd995272b3faff6"
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Labels = map[string]string{"app": "example"}
+		r.Spec.Pipeline.Params = []pollingv1.Param{
+			{Name: "one", Expression: "repository.name + '/' + repository.labels.app"},
+			{Name: "two", Expression: "ref + ' ' + previousSHA + ' ' + provider + ' ' + pollTime"},
+		}
+		r.Status.PollStatus = pollingv1.PollStatus{Ref: testRef, SHA: previousSHA}
+	})
+	_, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	want := []pipelinev1beta1.Param{
+		{Name: "one", Value: *pipelinev1beta1.NewArrayOrString(testRepositoryName + "/example")},
+		{Name: "two", Value: *pipelinev1beta1.NewArrayOrString(testRef + " " + previousSHA + " github 2020-10-07T12:00:00Z")},
+	}
+	opts := r.runner.(*pipelines.MockRunner).RunOptions(testPipelineName, testRepositoryNamespace)
+	if diff := cmp.Diff(want, opts.Params); diff != "" {
+		t.Fatalf("incorrect params:\n%s", diff)
+	}
+}
+
 func TestReconcileRepositoryInPipelineNamespace(t *testing.T) {
 	pipelineNS := "test-pipeline-ns"
 	logf.SetLogger(logf.ZapLogger(true))
 	repo := makeRepository(func(r *pollingv1.Repository) {
 		r.Spec.Pipeline.Namespace = pipelineNS
 	})
-	cl, r := makeReconciler(t, repo, repo)
+	cl, r := makeReconciler(t, repo, repo, makeTestPipeline(testPipelineName, pipelineNS, "one", "two"))
 	req := makeReconcileRequest()
 	ctx := context.Background()
 
@@ -110,7 +187,7 @@ func TestReconcileRepositoryInPipelineNamespace(t *testing.T) {
 	loaded := &pollingv1.Repository{}
 	err = cl.Get(ctx, req.NamespacedName, loaded)
 	fatalIfError(t, err)
-	r.pipelineRunner.(*pipelines.MockRunner).AssertPipelineRun(
+	r.runner.(*pipelines.MockRunner).AssertPipelineRun(
 		testPipelineName, pipelineNS,
 		testServiceAccountName,
 		makeTestParams(map[string]string{"one": testRepoURL, "two": "main"}),
@@ -122,12 +199,315 @@ func TestReconcileRepositoryInPipelineNamespace(t *testing.T) {
 			SHA:  "24317a55785cd98d6c9bf50a5204bc6be17e7316",
 			ETag: `W/"878f43039ad0553d0d3122d8bc171b01"`,
 		},
+		LastRun: &pollingv1.RunStatus{
+			Kind:      "PipelineRun",
+			Name:      testPipelineName,
+			Namespace: pipelineNS,
+			SHA:       testCommitSHA,
+		},
+		Conditions: []metav1.Condition{
+			{
+				Type:    pollingv1.PipelineValidCondition,
+				Status:  metav1.ConditionTrue,
+				Reason:  pollingv1.PipelineValidReason,
+				Message: "The params match the params declared by the Pipeline",
+			},
+		},
 	}
-	if diff := cmp.Diff(wantStatus, loaded.Status); diff != "" {
+	if diff := cmp.Diff(wantStatus, loaded.Status, ignoreTransitionTime); diff != "" {
 		t.Fatalf("incorrect repository status:\n%s", diff)
 	}
 }
 
+func TestReconcileRepositoryWithPipelineRunTemplate(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	tmpl := &pollingv1.PipelineRunTemplate{
+		Metadata: pollingv1.TemplateMetadata{
+			Labels: map[string]string{"app": "test"},
+		},
+		Spec: pollingv1.PipelineRunSpec{
+			PipelineRunSpec: pipelinev1beta1.PipelineRunSpec{
+				Timeout: &metav1.Duration{Duration: time.Minute * 20},
+			},
+		},
+	}
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.PipelineRunTemplate = tmpl
+	})
+	_, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	r.runner.(*pipelines.MockRunner).AssertPipelineRunTemplate(
+		testPipelineName, testRepositoryNamespace, tmpl)
+}
+
+func TestReconcileRepositoryWithPipelineSpec(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	spec := pipelinev1beta1.PipelineSpec{
+		Params: []pipelinev1beta1.ParamSpec{{Name: "one"}, {Name: "two"}},
+		Tasks: []pipelinev1beta1.PipelineTask{
+			{Name: "test-task", TaskRef: &pipelinev1beta1.TaskRef{Name: "test-task"}},
+		},
+	}
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline.Name = ""
+		r.Spec.PipelineSpec = &pollingv1.PipelineSpec{PipelineSpec: spec}
+	})
+	_, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	r.runner.(*pipelines.MockRunner).AssertRunOptions(
+		"", testRepositoryNamespace, pipelines.RunOptions{
+			Kind:               pipelines.PipelineRunKind,
+			Labels:             testCreatedRunLabels,
+			OwnerReferences:    testOwnerReferences,
+			ServiceAccountName: testServiceAccountName,
+			Params:             makeTestParams(map[string]string{"one": testRepoURL, "two": "main"}),
+			Resources:          testResources,
+			Workspaces:         testWorkspaces,
+			PipelineSpec:       &spec,
+		})
+}
+
+func TestReconcileRepositoryWithResolver(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	resolverParams := []pollingv1.ResolverParam{
+		{Name: "url", Value: testRepoURL},
+		{Name: "pathInRepo", Value: "pipeline.yaml"},
+	}
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline.Name = ""
+		r.Spec.Pipeline.Resolver = "git"
+		r.Spec.Pipeline.ResolverParams = resolverParams
+	})
+	_, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	r.runner.(*pipelines.MockRunner).AssertRunOptions(
+		"", testRepositoryNamespace, pipelines.RunOptions{
+			Kind:               pipelines.PipelineRunKind,
+			Labels:             testCreatedRunLabels,
+			OwnerReferences:    testOwnerReferences,
+			ServiceAccountName: testServiceAccountName,
+			Params:             makeTestParams(map[string]string{"one": testRepoURL, "two": "main"}),
+			Resources:          testResources,
+			Workspaces:         testWorkspaces,
+			Resolver:           "git",
+			ResolverParams:     resolverParams,
+		})
+}
+
+func TestReconcileRepositoryWithTektonAPIVersion(t *testing.T) {
+	apiVersionTests := []struct {
+		name       string
+		defaultVer pipelines.APIVersion
+		repoVer    string
+		resources  []pipelinev1beta1.PipelineResourceBinding
+		want       pipelines.APIVersion
+	}{
+		{"operator default", pipelines.V1, "", nil, pipelines.V1},
+		{"repository override", pipelines.V1, "v1beta1", nil, pipelines.V1Beta1},
+		{"operator default with resources", pipelines.V1, "", testResources, pipelines.V1Beta1},
+		{"repository override with resources", pipelines.V1Beta1, "v1beta1", testResources, pipelines.V1Beta1},
+	}
+
+	for _, tt := range apiVersionTests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := makeRepository(func(r *pollingv1.Repository) {
+				r.Spec.TektonAPIVersion = tt.repoVer
+				r.Spec.Pipeline.Resources = tt.resources
+			})
+			_, r := makeReconciler(t, repo, repo)
+			r.apiVersion = tt.defaultVer
+			req := makeReconcileRequest()
+
+			_, err := r.Reconcile(req)
+			fatalIfError(t, err)
+
+			r.runner.(*pipelines.MockRunner).AssertRunOptions(
+				testPipelineName, testRepositoryNamespace, pipelines.RunOptions{
+					Kind:               pipelines.PipelineRunKind,
+					Labels:             testCreatedRunLabels,
+					OwnerReferences:    testOwnerReferences,
+					APIVersion:         tt.want,
+					Name:               testPipelineName,
+					ServiceAccountName: testServiceAccountName,
+					Params:             makeTestParams(map[string]string{"one": testRepoURL, "two": "main"}),
+					Resources:          tt.resources,
+					Workspaces:         testWorkspaces,
+				})
+		})
+	}
+}
+
+func TestReconcileRepositoryWithTriggerTemplate(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline = pollingv1.PipelineRef{}
+		r.Spec.TriggerTemplate = &pollingv1.TriggerTemplateRef{Name: "test-template"}
+		r.Spec.Bindings = []pollingv1.Param{
+			{Name: "sha", Expression: "commit.id"},
+			{Name: "url", Expression: "repoURL"},
+		}
+	})
+	_, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	r.templateRunner.(*triggers.MockRunner).AssertTemplateRun(
+		types.NamespacedName{Name: "test-template", Namespace: testRepositoryNamespace},
+		map[string]string{"sha": "main", "url": testRepoURL})
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+}
+
+func TestReconcileRepositoryWithTriggerTemplateRetriesFailedRun(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	ctx := context.Background()
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline = pollingv1.PipelineRef{}
+		r.Spec.TriggerTemplate = &pollingv1.TriggerTemplateRef{Name: "test-template"}
+		r.Spec.Bindings = []pollingv1.Param{{Name: "sha", Expression: "commit.id"}}
+	})
+	cl, r := makeReconciler(t, repo, repo)
+	p := git.NewMockPoller()
+	p.AddMockResponse(testRepo, pollingv1.PollStatus{Ref: testRef},
+		map[string]interface{}{"id": testRef},
+		pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA, ETag: testCommitETag})
+	p.AddMockResponse(testRepo, pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA},
+		map[string]interface{}{"id": testRef},
+		pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA, ETag: testCommitETag})
+	r.pollerFactory = func(*pollingv1.Repository, string, string) git.CommitPoller {
+		return p
+	}
+	runner := r.templateRunner.(*triggers.MockRunner)
+	failingErr := errors.New("failed to create")
+	runner.FailWithError(failingErr)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	if err != failingErr {
+		t.Fatalf("got %#v, want %#v", err, failingErr)
+	}
+
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	wantPending := &pollingv1.PendingTrigger{SHA: testCommitSHA, ID: testTriggerID}
+	if diff := cmp.Diff(wantPending, loaded.Status.PendingTrigger); diff != "" {
+		t.Fatalf("incorrect pending trigger:\n%s", diff)
+	}
+
+	runner.FailWithError(nil)
+	_, err = r.Reconcile(req)
+	fatalIfError(t, err)
+
+	template := types.NamespacedName{Name: "test-template", Namespace: testRepositoryNamespace}
+	runner.AssertTemplateRun(template, map[string]string{"sha": "main"})
+	runner.AssertTemplateRunOptions(template, triggers.RunOptions{
+		Labels:       map[string]string{pollingv1.TriggerIDLabel: testTriggerID},
+		SkipExisting: true,
+	})
+	retried := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, retried))
+	if retried.Status.PendingTrigger != nil {
+		t.Fatalf("pending trigger was not cleared: %#v", retried.Status.PendingTrigger)
+	}
+}
+
+func TestReconcileRepositoryWithTask(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline = pollingv1.PipelineRef{}
+		r.Spec.Task = &pollingv1.TaskRef{
+			Name:               testTaskName,
+			Namespace:          "task-ns",
+			ServiceAccountName: testServiceAccountName,
+			Params: []pollingv1.Param{
+				{Name: "sha", Expression: "commit.id"},
+			},
+			Workspaces: testWorkspaces,
+		}
+	})
+	_, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	r.runner.(*pipelines.MockRunner).AssertRunOptions(
+		testTaskName, "task-ns", pipelines.RunOptions{
+			Kind: pipelines.TaskRunKind,
+			// The TaskRun is in a different namespace, so it can't be owned by
+			// the Repository.
+			Labels:             testCreatedRunLabels,
+			Name:               testTaskName,
+			ServiceAccountName: testServiceAccountName,
+			Params:             makeTestParams(map[string]string{"sha": "main"}),
+			Workspaces:         testWorkspaces,
+		})
+}
+
+func TestReconcileRepositoryWithTaskSpec(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	spec := pipelinev1beta1.TaskSpec{
+		Steps: []pipelinev1beta1.Step{
+			{Container: corev1.Container{Name: "lint", Image: "golangci/golangci-lint"}},
+		},
+	}
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline = pollingv1.PipelineRef{}
+		r.Spec.TaskSpec = &pollingv1.TaskSpec{TaskSpec: spec}
+	})
+	_, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	r.runner.(*pipelines.MockRunner).AssertRunOptions(
+		"", testRepositoryNamespace, pipelines.RunOptions{
+			Kind:            pipelines.TaskRunKind,
+			Labels:          testCreatedRunLabels,
+			OwnerReferences: testOwnerReferences,
+			Params:          []pipelinev1beta1.Param{},
+			TaskSpec:        &spec,
+		})
+}
+
+func TestReconcileRepositoryWithInvalidRepository(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	ctx := context.Background()
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.PipelineSpec = &pollingv1.PipelineSpec{}
+	})
+	cl, r := makeReconciler(t, repo, repo)
+	req := makeReconcileRequest()
+
+	res, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	if diff := cmp.Diff(reconcile.Result{}, res); diff != "" {
+		t.Fatalf("reconciliation result is different:\n%s", diff)
+	}
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	want := "only one of pipelineRef.name and pipelineSpec can be provided"
+	if loaded.Status.LastError != want {
+		t.Fatalf("got LastError %#v, want %#v", loaded.Status.LastError, want)
+	}
+}
+
 func TestReconcileRepositoryWithAuthSecret(t *testing.T) {
 	authTests := []struct {
 		authSecret pollingv1.AuthSecret
@@ -204,7 +584,7 @@ func TestReconcileRepositoryErrorPolling(t *testing.T) {
 	if diff := cmp.Diff(wantStatus, loaded.Status); diff != "" {
 		t.Fatalf("incorrect repository status:\n%s", diff)
 	}
-	r.pipelineRunner.(*pipelines.MockRunner).AssertNoPipelineRuns()
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
 }
 
 func TestReconcileRepositoryWithUnchangedState(t *testing.T) {
@@ -230,12 +610,221 @@ func TestReconcileRepositoryWithUnchangedState(t *testing.T) {
 	req := makeReconcileRequest()
 	_, err := r.Reconcile(req)
 	fatalIfError(t, err)
-	r.pipelineRunner = pipelines.NewMockRunner(t)
+	r.runner = pipelines.NewMockRunner(t)
+
+	_, err = r.Reconcile(req)
+
+	fatalIfError(t, err)
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+}
+
+func TestReconcileRepositoryWithTriggerAnnotation(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	ctx := context.Background()
+	repo := makeRepository()
+	cl, r := makeReconciler(t, repo, repo)
+	p := git.NewMockPoller()
+	p.AddMockResponse(testRepo, pollingv1.PollStatus{Ref: testRef},
+		map[string]interface{}{"id": testRef},
+		pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA,
+			ETag: testCommitETag})
+	p.AddMockResponse(
+		testRepo, pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA,
+			ETag: testCommitETag},
+		nil,
+		pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA,
+			ETag: testCommitETag})
+	p.AddMockResponse(
+		testRepo, pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA},
+		map[string]interface{}{"id": testRef},
+		pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA,
+			ETag: testCommitETag})
+	r.pollerFactory = func(_ *pollingv1.Repository, endpoint, token string) git.CommitPoller {
+		return p
+	}
+	req := makeReconcileRequest()
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	loaded.Annotations = map[string]string{pollingv1.TriggerAnnotation: "run-1"}
+	fatalIfError(t, cl.Update(ctx, loaded))
+	r.runner = pipelines.NewMockRunner(t)
+
+	_, err = r.Reconcile(req)
+	fatalIfError(t, err)
+
+	r.runner.(*pipelines.MockRunner).AssertPipelineRun(
+		testPipelineName, testRepositoryNamespace,
+		testServiceAccountName,
+		makeTestParams(map[string]string{"one": testRepoURL, "two": "main"}),
+		testResources, testWorkspaces)
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	if loaded.Status.LastTrigger != "run-1" {
+		t.Fatalf("got LastTrigger %#v, want %#v", loaded.Status.LastTrigger, "run-1")
+	}
+
+	r.runner = pipelines.NewMockRunner(t)
+	_, err = r.Reconcile(req)
+	fatalIfError(t, err)
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+}
+
+func TestReconcileRepositoryDuringBlackoutDefersRun(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	ctx := context.Background()
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.BlackoutWindows = []pollingv1.BlackoutWindow{testBlackoutWindow}
+	})
+	cl, r := makeReconciler(t, repo, repo)
+	fakeClock := clock.NewFakeClock(time.Date(2020, time.October, 12, 7, 59, 55, 0, time.UTC))
+	r.clock = fakeClock
+	p := git.NewMockPoller()
+	p.AddMockResponse(testRepo, pollingv1.PollStatus{Ref: testRef},
+		map[string]interface{}{"id": testRef},
+		pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA,
+			ETag: testCommitETag})
+	p.AddMockResponse(
+		testRepo, pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA},
+		map[string]interface{}{"id": testRef},
+		pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA,
+			ETag: testCommitETag})
+	r.pollerFactory = func(_ *pollingv1.Repository, endpoint, token string) git.CommitPoller {
+		return p
+	}
+	req := makeReconcileRequest()
+
+	res, err := r.Reconcile(req)
+	fatalIfError(t, err)
 
+	if diff := cmp.Diff(reconcile.Result{RequeueAfter: time.Second * 5}, res); diff != "" {
+		t.Fatalf("reconciliation result is different:\n%s", diff)
+	}
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	if loaded.Status.DeferredSHA != testCommitSHA {
+		t.Fatalf("got DeferredSHA %#v, want %#v", loaded.Status.DeferredSHA, testCommitSHA)
+	}
+
+	fakeClock.Step(time.Second * 5)
 	_, err = r.Reconcile(req)
+	fatalIfError(t, err)
+
+	r.runner.(*pipelines.MockRunner).AssertPipelineRun(
+		testPipelineName, testRepositoryNamespace,
+		testServiceAccountName,
+		makeTestParams(map[string]string{"one": testRepoURL, "two": "main"}),
+		testResources, testWorkspaces)
+	loaded = &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	if loaded.Status.DeferredSHA != "" {
+		t.Fatalf("got DeferredSHA %#v, want it to be cleared", loaded.Status.DeferredSHA)
+	}
+}
+
+func TestReconcileRepositoryDuringBlackoutDropsRun(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	ctx := context.Background()
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.BlackoutWindows = []pollingv1.BlackoutWindow{testBlackoutWindow}
+		r.Spec.BlackoutPolicy = pollingv1.BlackoutDrop
+	})
+	cl, r := makeReconciler(t, repo, repo)
+	r.clock = clock.NewFakeClock(time.Date(2020, time.October, 10, 12, 0, 0, 0, time.UTC))
+	req := makeReconcileRequest()
+
+	res, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	if diff := cmp.Diff(reconcile.Result{RequeueAfter: testFrequency}, res); diff != "" {
+		t.Fatalf("reconciliation result is different:\n%s", diff)
+	}
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	wantStatus := pollingv1.RepositoryStatus{
+		PollStatus: pollingv1.PollStatus{
+			Ref:  testRef,
+			SHA:  testCommitSHA,
+			ETag: testCommitETag,
+		},
+	}
+	if diff := cmp.Diff(wantStatus, loaded.Status); diff != "" {
+		t.Fatalf("incorrect repository status:\n%s", diff)
+	}
+}
 
+func TestReconcileRepositoryWithJitter(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository()
+	_, r := makeReconciler(t, repo, repo)
+	r.jitter = time.Minute
+	req := makeReconcileRequest()
+
+	res, err := r.Reconcile(req)
 	fatalIfError(t, err)
-	r.pipelineRunner.(*pipelines.MockRunner).AssertNoPipelineRuns()
+
+	want := reconcile.Result{RequeueAfter: testFrequency + time.Duration(27300356552)}
+	if diff := cmp.Diff(want, res); diff != "" {
+		t.Fatalf("reconciliation result is different:\n%s", diff)
+	}
+}
+
+func TestReconcileRepositoryWithRepositoryJitter(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Jitter = &metav1.Duration{Duration: time.Second * 5}
+	})
+	_, r := makeReconciler(t, repo, repo)
+	r.jitter = time.Minute
+	req := makeReconcileRequest()
+
+	res, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	want := reconcile.Result{RequeueAfter: testFrequency + time.Duration(2300356552)}
+	if diff := cmp.Diff(want, res); diff != "" {
+		t.Fatalf("reconciliation result is different:\n%s", diff)
+	}
+}
+
+func TestReconcileRepositoryDelaysFirstPollOfPolledRepository(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Status.PollStatus = pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA, ETag: testCommitETag}
+	})
+	_, r := makeReconciler(t, repo, repo)
+	r.jitter = time.Minute
+	p := git.NewMockPoller()
+	p.AddMockResponse(
+		testRepo, pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA,
+			ETag: testCommitETag},
+		nil,
+		pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA,
+			ETag: testCommitETag})
+	r.pollerFactory = func(_ *pollingv1.Repository, endpoint, token string) git.CommitPoller {
+		return p
+	}
+	req := makeReconcileRequest()
+
+	res, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	want := reconcile.Result{RequeueAfter: time.Duration(27300356552)}
+	if diff := cmp.Diff(want, res); diff != "" {
+		t.Fatalf("reconciliation result is different:\n%s", diff)
+	}
+
+	res, err = r.Reconcile(req)
+	fatalIfError(t, err)
+
+	want = reconcile.Result{RequeueAfter: testFrequency + time.Duration(27300356552)}
+	if diff := cmp.Diff(want, res); diff != "" {
+		t.Fatalf("reconciliation result is different:\n%s", diff)
+	}
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
 }
 
 func TestReconcileRepositoryClearsLastErrorOnSuccessfulPoll(t *testing.T) {
@@ -270,6 +859,33 @@ func TestReconcileRepositoryClearsLastErrorOnSuccessfulPoll(t *testing.T) {
 	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
 }
 
+func TestReconcileRepositoryClearsLastErrorWhenUnchanged(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	ctx := context.Background()
+	polled := pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA, ETag: testCommitETag}
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Status.PollStatus = polled
+		r.Status.LastError = "failing"
+	})
+	cl, r := makeReconciler(t, repo, repo)
+	p := git.NewMockPoller()
+	p.AddMockResponse(testRepo, polled, map[string]interface{}{"id": testRef}, polled)
+	r.pollerFactory = func(*pollingv1.Repository, string, string) git.CommitPoller {
+		return p
+	}
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	if loaded.Status.LastError != "" {
+		t.Fatalf("got LastError %#v, want it to be cleared", loaded.Status.LastError)
+	}
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+}
+
 func Test_repoFromURL(t *testing.T) {
 	urlTests := []struct {
 		url          string
@@ -297,11 +913,124 @@ func Test_repoFromURL(t *testing.T) {
 	}
 }
 
+func TestReconcileRepositoryRetriesFailedRuns(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	ctx := context.Background()
+	repo := makeRepository()
+	cl, r := makeReconciler(t, repo, repo)
+	p := git.NewMockPoller()
+	p.AddMockResponse(testRepo, pollingv1.PollStatus{Ref: testRef},
+		map[string]interface{}{"id": testRef},
+		pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA,
+			ETag: testCommitETag})
+	p.AddMockResponse(
+		testRepo, pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA},
+		map[string]interface{}{"id": testRef},
+		pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA,
+			ETag: testCommitETag})
+	r.pollerFactory = func(_ *pollingv1.Repository, endpoint, token string) git.CommitPoller {
+		return p
+	}
+	runner := r.runner.(*pipelines.MockRunner)
+	runner.FailWithError(errors.New("failed to create a run"))
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	if err == nil {
+		t.Fatal("expected the run creation to fail")
+	}
+
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	wantPending := &pollingv1.PendingTrigger{SHA: testCommitSHA, ID: testTriggerID}
+	if diff := cmp.Diff(wantPending, loaded.Status.PendingTrigger); diff != "" {
+		t.Fatalf("incorrect pending trigger:\n%s", diff)
+	}
+
+	runner.FailWithError(nil)
+	_, err = r.Reconcile(req)
+	fatalIfError(t, err)
+
+	runner.AssertRunOptions(testPipelineName, testRepositoryNamespace, pipelines.RunOptions{
+		Kind:               pipelines.PipelineRunKind,
+		Name:               testPipelineName,
+		Labels:             testCreatedRunLabels,
+		OwnerReferences:    testOwnerReferences,
+		ServiceAccountName: testServiceAccountName,
+		Params:             makeTestParams(map[string]string{"one": testRepoURL, "two": "main"}),
+		Resources:          testResources,
+		Workspaces:         testWorkspaces,
+	})
+	loaded = &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	if loaded.Status.PendingTrigger != nil {
+		t.Fatalf("got PendingTrigger %#v, want it to be cleared", loaded.Status.PendingTrigger)
+	}
+}
+
+func TestReconcileRepositoryWithPendingTriggerAlreadyCreated(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	ctx := context.Background()
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Status.PollStatus = pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA, ETag: testCommitETag}
+		r.Status.PendingTrigger = &pollingv1.PendingTrigger{SHA: testCommitSHA, ID: "created-trigger"}
+	})
+	cl, r := makeReconciler(t, repo, repo)
+	p := git.NewMockPoller()
+	p.AddMockResponse(
+		testRepo, pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA},
+		map[string]interface{}{"id": testRef},
+		pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA,
+			ETag: testCommitETag})
+	r.pollerFactory = func(_ *pollingv1.Repository, endpoint, token string) git.CommitPoller {
+		return p
+	}
+	runner := r.runner.(*pipelines.MockRunner)
+	run := makeTestRun("created", "")
+	run.SetLabels(map[string]string{
+		pollingv1.RepositoryLabel:          testRepositoryName,
+		pollingv1.RepositoryNamespaceLabel: testRepositoryNamespace,
+		pollingv1.TriggerIDLabel:           "created-trigger",
+	})
+	runner.AddRuns(run)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	runner.AssertNoRuns()
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(ctx, req.NamespacedName, loaded))
+	if loaded.Status.PendingTrigger != nil {
+		t.Fatalf("got PendingTrigger %#v, want it to be cleared", loaded.Status.PendingTrigger)
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	labelTests := []struct {
+		in   string
+		want string
+	}{
+		{"main", "main"},
+		{"feature/new-thing", "feature-new-thing"},
+		{"refs/heads/main", "refs-heads-main"},
+		{"/leading", "leading"},
+		{strings.Repeat("a", 70), strings.Repeat("a", 63)},
+	}
+
+	for _, tt := range labelTests {
+		if v := labelValue(tt.in); v != tt.want {
+			t.Errorf("labelValue(%q) got %q, want %q", tt.in, v, tt.want)
+		}
+	}
+}
+
 func makeRepository(opts ...func(*pollingv1.Repository)) *pollingv1.Repository {
 	r := &pollingv1.Repository{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      testRepositoryName,
 			Namespace: testRepositoryNamespace,
+			UID:       testRepositoryUID,
 		},
 		Spec: pollingv1.RepositorySpec{
 			URL:       testRepoURL,
@@ -355,6 +1084,8 @@ func makeTestSecret(n, key string) *corev1.Secret {
 func makeReconciler(t *testing.T, pr *pollingv1.Repository, objs ...runtime.Object) (client.Client, *ReconcileRepository) {
 	s := scheme.Scheme
 	s.AddKnownTypes(pollingv1.SchemeGroupVersion, pr)
+	s.AddKnownTypes(pipelinev1beta1.SchemeGroupVersion, &pipelinev1beta1.Pipeline{})
+	objs = append(objs, makeTestPipeline(testPipelineName, testRepositoryNamespace, "one", "two"))
 	cl := fake.NewFakeClientWithScheme(s, objs...)
 	p := git.NewMockPoller()
 	p.AddMockResponse(testRepo, pollingv1.PollStatus{Ref: testRef},
@@ -366,12 +1097,31 @@ func makeReconciler(t *testing.T, pr *pollingv1.Repository, objs ...runtime.Obje
 	}
 	return cl, &ReconcileRepository{
 		client:         cl,
+		apiReader:      cl,
 		scheme:         s,
 		pollerFactory:  pollerFactory,
-		pipelineRunner: pipelines.NewMockRunner(t),
+		runner:         pipelines.NewMockRunner(t),
+		templateRunner: triggers.NewMockRunner(t),
 		secretGetter:   secrets.New(cl),
 		log:            logf.Log.WithName("testing"),
+		clock:          clock.NewFakeClock(time.Date(2020, time.October, 7, 12, 0, 0, 0, time.UTC)),
+		seen:           make(map[types.NamespacedName]bool),
+		triggerID:      func() string { return testTriggerID },
+	}
+}
+
+// makeTestPipeline returns a Pipeline that declares the named string params.
+func makeTestPipeline(name, ns string, params ...string) *pipelinev1beta1.Pipeline {
+	p := &pipelinev1beta1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
 	}
+	for _, n := range params {
+		p.Spec.Params = append(p.Spec.Params, pipelinev1beta1.ParamSpec{Name: n, Type: pipelinev1beta1.ParamTypeString})
+	}
+	return p
 }
 
 func fatalIfError(t *testing.T, err error) {
@@ -382,10 +1132,55 @@ func fatalIfError(t *testing.T, err error) {
 }
 
 func makeTestParams(vars map[string]string) []pipelinev1beta1.Param {
+	keys := []string{}
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 	params := []pipelinev1beta1.Param{}
-	for k, v := range vars {
+	for _, k := range keys {
 		params = append(params, pipelinev1beta1.Param{
-			Name: k, Value: *pipelinev1beta1.NewArrayOrString(v)})
+			Name: k, Value: *pipelinev1beta1.NewArrayOrString(vars[k])})
 	}
 	return params
 }
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestRepositoryChanged(t *testing.T) {
+	changeTests := []struct {
+		name   string
+		update func(r *pollingv1.Repository)
+		want   bool
+	}{
+		{"status changed", func(r *pollingv1.Repository) {
+			r.Status.LastRun = &pollingv1.RunStatus{Name: "test-run", Succeeded: corev1.ConditionTrue}
+		}, false},
+		{"spec changed", func(r *pollingv1.Repository) {
+			r.Spec.Ref = "release"
+			r.Generation++
+		}, true},
+		{"trigger annotation changed", func(r *pollingv1.Repository) {
+			r.Annotations = map[string]string{pollingv1.TriggerAnnotation: "now"}
+		}, true},
+		{"other annotation changed", func(r *pollingv1.Repository) {
+			r.Annotations = map[string]string{"example.com/owner": "team-a"}
+		}, false},
+	}
+
+	for _, tt := range changeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := makeRepository()
+			updated := old.DeepCopy()
+			tt.update(updated)
+
+			got := repositoryChanged.Update(event.UpdateEvent{MetaOld: old, ObjectOld: old, MetaNew: updated, ObjectNew: updated})
+
+			if got != tt.want {
+				t.Fatalf("repositoryChanged.Update() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/git"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/secrets"
+)
+
+// addRunStatus creates a controller that watches the runs of the kind, and
+// records the outcome of the most recent run in the Repository's status.
+//
+// This is separate from the Repository controller, so that changes to the
+// runs don't trigger polls, the Repository controller ignores the updates to
+// the status that are made here.
+func addRunStatus(mgr manager.Manager, kind pipelines.RunKind) error {
+	r := &ReconcileRunStatus{
+		client:          mgr.GetClient(),
+		kind:            kind,
+		secretGetter:    secrets.New(mgr.GetClient()),
+		reporterFactory: makeStatusReporter,
+		checksFactory:   makeChecksReporter(git.NewInstallationTokens()),
+		log:             logf.Log.WithName("controller_run_status").WithValues("kind", kind),
+	}
+	c, err := controller.New("repository-"+string(kind)+"-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: newRun(kind)}, &handler.EnqueueRequestForObject{}, createdByRepository)
+}
+
+// createdByRepository filters out events for runs that weren't created for a
+// Repository.
+var createdByRepository = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		return e.Meta.GetLabels()[pollingv1.RepositoryLabel] != ""
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.MetaNew.GetLabels()[pollingv1.RepositoryLabel] != ""
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return false
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+// ReconcileRunStatus records the outcome of runs in the status of the
+// Repository that created them.
+type ReconcileRunStatus struct {
+	client       client.Client
+	kind         pipelines.RunKind
+	secretGetter secrets.SecretGetter
+	// The reporterFactory creates the reporter for commit statuses.
+	reporterFactory statusReporterFactory
+	// The checksFactory creates the reporter for GitHub check runs.
+	checksFactory checksReporterFactory
+	log           logr.Logger
+}
+
+// Reconcile reports the status of the run, and updates the LastRun or LastRuns
+// of the Repository that created the run, if the run is one of the most recent
+// runs for the Repository.
+func (r *ReconcileRunStatus) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	reqLogger := r.log.WithValues("Request.Namespace", req.Namespace, "Request.Name", req.Name)
+	ctx := context.Background()
+
+	run := newRun(r.kind)
+	if err := r.client.Get(ctx, req.NamespacedName, run); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	labels := run.GetLabels()
+	repoName := types.NamespacedName{
+		Name:      labels[pollingv1.RepositoryLabel],
+		Namespace: labels[pollingv1.RepositoryNamespaceLabel],
+	}
+	if repoName.Name == "" || repoName.Namespace == "" {
+		return reconcile.Result{}, nil
+	}
+	repo := &pollingv1.Repository{}
+	if err := r.client.Get(ctx, repoName, repo); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	reported := runStatus(run, labels[pollingv1.SHALabel])
+	reported.Pipeline = labels[pollingv1.PipelineLabel]
+	if err := r.reportStatus(ctx, reqLogger, repo, run, reported); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.reportChecks(ctx, reqLogger, repo, run, reported); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	last := findLastRun(repo, r.kind, run)
+	if last == nil {
+		// Only the outcome of the most recent runs is recorded.
+		return reconcile.Result{}, nil
+	}
+	updated := runStatus(run, last.SHA)
+	updated.Pipeline = last.Pipeline
+	if equality.Semantic.DeepEqual(last, updated) {
+		return reconcile.Result{}, nil
+	}
+	*last = *updated
+	if err := r.client.Status().Update(ctx, repo); err != nil {
+		reqLogger.Error(err, "unable to update Repository status", "repository", repoName)
+		return reconcile.Result{}, err
+	}
+	reqLogger.Info("Recorded run status", "repository", repoName, "succeeded", updated.Succeeded, "reason", updated.Reason)
+	return reconcile.Result{}, nil
+}
+
+// annotateRun records the annotations on the run.
+func (r *ReconcileRunStatus) annotateRun(ctx context.Context, run pipelines.RunObject, values map[string]string) error {
+	patch := client.MergeFrom(run.DeepCopyObject())
+	annotations := run.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	for k, v := range values {
+		annotations[k] = v
+	}
+	run.SetAnnotations(annotations)
+	return r.client.Patch(ctx, run, patch)
+}
+
+// findLastRun returns the recorded status of the run, if it's one of the most
+// recent runs for the Repository.
+func findLastRun(repo *pollingv1.Repository, kind pipelines.RunKind, run pipelines.RunObject) *pollingv1.RunStatus {
+	matches := func(s *pollingv1.RunStatus) bool {
+		return s.Kind == string(kind) && s.Name == run.GetName() && s.Namespace == run.GetNamespace()
+	}
+	if last := repo.Status.LastRun; last != nil && matches(last) {
+		return last
+	}
+	for i := range repo.Status.LastRuns {
+		if matches(&repo.Status.LastRuns[i]) {
+			return &repo.Status.LastRuns[i]
+		}
+	}
+	return nil
+}
+
+// newRun returns an empty run of the kind to fetch.
+//
+// Runs are always fetched through the v1beta1 types, the API server converts
+// runs that were created as v1 runs.
+func newRun(kind pipelines.RunKind) pipelines.RunObject {
+	if kind == pipelines.TaskRunKind {
+		return &pipelinev1.TaskRun{}
+	}
+	return &pipelinev1.PipelineRun{}
+}
+
+// runStatus returns the RunStatus for a run created for the SHA.
+func runStatus(run pipelines.RunObject, sha string) *pollingv1.RunStatus {
+	rs := &pollingv1.RunStatus{
+		Name:      run.GetName(),
+		Namespace: run.GetNamespace(),
+		SHA:       sha,
+	}
+	switch v := run.(type) {
+	case *pipelinev1.PipelineRun:
+		rs.Kind = string(pipelines.PipelineRunKind)
+		rs.StartTime = v.Status.StartTime
+		rs.CompletionTime = v.Status.CompletionTime
+		if c := v.Status.GetCondition("Succeeded"); c != nil {
+			rs.Succeeded = c.Status
+			rs.Reason = c.Reason
+		}
+	case *pipelinev1.TaskRun:
+		rs.Kind = string(pipelines.TaskRunKind)
+		rs.StartTime = v.Status.StartTime
+		rs.CompletionTime = v.Status.CompletionTime
+		if c := v.Status.GetCondition("Succeeded"); c != nil {
+			rs.Succeeded = c.Status
+			rs.Reason = c.Reason
+		}
+	}
+	return rs
+}
@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/git"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/secrets"
+)
+
+var (
+	testStartTime      = metav1.NewTime(time.Date(2020, time.October, 7, 12, 0, 0, 0, time.UTC))
+	testCompletionTime = metav1.NewTime(time.Date(2020, time.October, 7, 12, 5, 0, 0, time.UTC))
+)
+
+func TestReconcileRunStatusRecordsLastRun(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Status.LastRun = &pollingv1.RunStatus{
+			Kind:      "PipelineRun",
+			Name:      "test-run",
+			Namespace: testRepositoryNamespace,
+			SHA:       testCommitSHA,
+		}
+	})
+	pr := &pipelinev1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-run",
+			Namespace: testRepositoryNamespace,
+			Labels:    testRunLabels,
+		},
+	}
+	pr.Status.StartTime = &testStartTime
+	pr.Status.CompletionTime = &testCompletionTime
+	pr.Status.Conditions = duckv1beta1.Conditions{
+		{Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse, Reason: "Failed"},
+	}
+	cl, r := makeRunStatusReconciler(pipelines.PipelineRunKind, repo, pr)
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-run", Namespace: testRepositoryNamespace}})
+	fatalIfError(t, err)
+
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), types.NamespacedName{Name: testRepositoryName, Namespace: testRepositoryNamespace}, loaded))
+	want := &pollingv1.RunStatus{
+		Kind:           "PipelineRun",
+		Name:           "test-run",
+		Namespace:      testRepositoryNamespace,
+		SHA:            testCommitSHA,
+		StartTime:      &testStartTime,
+		CompletionTime: &testCompletionTime,
+		Succeeded:      corev1.ConditionFalse,
+		Reason:         "Failed",
+	}
+	if diff := cmp.Diff(want, loaded.Status.LastRun); diff != "" {
+		t.Fatalf("incorrect last run:\n%s", diff)
+	}
+}
+
+func TestReconcileRunStatusIgnoresOlderRuns(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	lastRun := &pollingv1.RunStatus{
+		Kind:      "TaskRun",
+		Name:      "new-run",
+		Namespace: testRepositoryNamespace,
+		SHA:       testCommitSHA,
+	}
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Status.LastRun = lastRun
+	})
+	tr := &pipelinev1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "old-run",
+			Namespace: testRepositoryNamespace,
+			Labels:    testRunLabels,
+		},
+	}
+	tr.Status.Conditions = duckv1beta1.Conditions{
+		{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue, Reason: "Succeeded"},
+	}
+	cl, r := makeRunStatusReconciler(pipelines.TaskRunKind, repo, tr)
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "old-run", Namespace: testRepositoryNamespace}})
+	fatalIfError(t, err)
+
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), types.NamespacedName{Name: testRepositoryName, Namespace: testRepositoryNamespace}, loaded))
+	if diff := cmp.Diff(lastRun, loaded.Status.LastRun); diff != "" {
+		t.Fatalf("last run was updated:\n%s", diff)
+	}
+}
+
+func TestReconcileRunStatusReportsCommitStatus(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.ReportStatus = &pollingv1.ReportStatus{
+			TargetURL: "https://dashboard.example.com/#/namespaces/{{.Namespace}}/pipelineruns/{{.Name}}",
+		}
+		r.Status.LastRun = &pollingv1.RunStatus{
+			Kind:      "PipelineRun",
+			Name:      "test-run",
+			Namespace: testRepositoryNamespace,
+			SHA:       testCommitSHA,
+			Succeeded: corev1.ConditionUnknown,
+			Reason:    "Running",
+		}
+	})
+	pr := &pipelinev1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-run",
+			Namespace: testRepositoryNamespace,
+			Labels:    testCreatedRunLabels,
+		},
+	}
+	pr.Status.Conditions = duckv1beta1.Conditions{
+		{Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse, Reason: "Failed"},
+	}
+	cl, r := makeRunStatusReconciler(pipelines.PipelineRunKind, repo, pr)
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-run", Namespace: testRepositoryNamespace}})
+	fatalIfError(t, err)
+
+	reporter := r.reporterFactory(repo, "", "").(*git.MockStatusReporter)
+	want := []git.CommitStatus{
+		{
+			State:       git.StatusFailure,
+			Context:     pollingv1.DefaultStatusContext,
+			Description: "The PipelineRun failed: Failed",
+			TargetURL:   "https://dashboard.example.com/#/namespaces/test-repository-ns/pipelineruns/test-run",
+		},
+	}
+	if diff := cmp.Diff(want, reporter.Statuses(testRepo, testCommitSHA)); diff != "" {
+		t.Fatalf("incorrect commit statuses:\n%s", diff)
+	}
+	loaded := &pipelinev1.PipelineRun{}
+	fatalIfError(t, cl.Get(context.Background(), types.NamespacedName{Name: "test-run", Namespace: testRepositoryNamespace}, loaded))
+	if a := loaded.Annotations[pollingv1.ReportedStatusAnnotation]; a != "failure" {
+		t.Fatalf("got reported status %q, want %q", a, "failure")
+	}
+}
+
+func TestReconcileRunStatusReportsSupersededRuns(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	newSHA := "7d5c2fe6b3f3b6ac1a2a0c1a5d2e0b5f8c9d7e6a"
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.ReportStatus = &pollingv1.ReportStatus{}
+		// The run for a newer commit started before the older run completed.
+		r.Status.LastRun = &pollingv1.RunStatus{
+			Kind:      "PipelineRun",
+			Name:      "new-run",
+			Namespace: testRepositoryNamespace,
+			SHA:       newSHA,
+			Succeeded: corev1.ConditionUnknown,
+		}
+	})
+	old := &pipelinev1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "old-run",
+			Namespace:   testRepositoryNamespace,
+			Labels:      testCreatedRunLabels,
+			Annotations: map[string]string{pollingv1.ReportedStatusAnnotation: "pending"},
+		},
+	}
+	old.Status.Conditions = duckv1beta1.Conditions{
+		{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue, Reason: "Succeeded"},
+	}
+	cl, r := makeRunStatusReconciler(pipelines.PipelineRunKind, repo, old)
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "old-run", Namespace: testRepositoryNamespace}})
+	fatalIfError(t, err)
+
+	reporter := r.reporterFactory(repo, "", "").(*git.MockStatusReporter)
+	want := []git.CommitStatus{
+		{
+			State:       git.StatusSuccess,
+			Context:     pollingv1.DefaultStatusContext,
+			Description: "The PipelineRun succeeded",
+		},
+	}
+	if diff := cmp.Diff(want, reporter.Statuses(testRepo, testCommitSHA)); diff != "" {
+		t.Fatalf("incorrect commit statuses:\n%s", diff)
+	}
+	if s := reporter.Statuses(testRepo, newSHA); len(s) != 0 {
+		t.Fatalf("unexpected commit statuses for the newer commit: %#v", s)
+	}
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), types.NamespacedName{Name: testRepositoryName, Namespace: testRepositoryNamespace}, loaded))
+	if diff := cmp.Diff(repo.Status.LastRun, loaded.Status.LastRun); diff != "" {
+		t.Fatalf("the last run was updated:\n%s", diff)
+	}
+}
+
+func TestReconcileRunStatusReportingStatuses(t *testing.T) {
+	statusTests := []struct {
+		name         string
+		reportStatus *pollingv1.ReportStatus
+		reported     string
+		current      corev1.ConditionStatus
+		want         []git.CommitStatus
+	}{
+		{"not configured", nil, "", corev1.ConditionTrue, nil},
+		{"run started", &pollingv1.ReportStatus{Context: "ci"}, "", corev1.ConditionUnknown,
+			[]git.CommitStatus{{State: git.StatusPending, Context: "ci", Description: "The PipelineRun is running"}}},
+		{"run still running", &pollingv1.ReportStatus{}, "pending", corev1.ConditionUnknown, nil},
+		{"run succeeded", &pollingv1.ReportStatus{Context: "ci"}, "pending", corev1.ConditionTrue,
+			[]git.CommitStatus{{State: git.StatusSuccess, Context: "ci", Description: "The PipelineRun succeeded"}}},
+		{"success already reported", &pollingv1.ReportStatus{}, "success", corev1.ConditionTrue, nil},
+	}
+
+	for _, tt := range statusTests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := makeRepository(func(r *pollingv1.Repository) {
+				r.Spec.ReportStatus = tt.reportStatus
+				r.Status.LastRun = &pollingv1.RunStatus{
+					Kind:      "PipelineRun",
+					Name:      "test-run",
+					Namespace: testRepositoryNamespace,
+					SHA:       testCommitSHA,
+				}
+			})
+			pr := &pipelinev1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-run",
+					Namespace: testRepositoryNamespace,
+					Labels:    testCreatedRunLabels,
+				},
+			}
+			if tt.reported != "" {
+				pr.Annotations = map[string]string{pollingv1.ReportedStatusAnnotation: tt.reported}
+			}
+			pr.Status.StartTime = &testStartTime
+			pr.Status.Conditions = duckv1beta1.Conditions{
+				{Type: apis.ConditionSucceeded, Status: tt.current},
+			}
+			_, r := makeRunStatusReconciler(pipelines.PipelineRunKind, repo, pr)
+
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-run", Namespace: testRepositoryNamespace}})
+			fatalIfError(t, err)
+
+			reporter := r.reporterFactory(repo, "", "").(*git.MockStatusReporter)
+			if diff := cmp.Diff(tt.want, reporter.Statuses(testRepo, testCommitSHA)); diff != "" {
+				t.Fatalf("incorrect commit statuses:\n%s", diff)
+			}
+		})
+	}
+}
+
+func makeRunStatusReconciler(kind pipelines.RunKind, objs ...runtime.Object) (client.Client, *ReconcileRunStatus) {
+	s := scheme.Scheme
+	s.AddKnownTypes(pollingv1.SchemeGroupVersion, &pollingv1.Repository{})
+	s.AddKnownTypes(pipelinev1.SchemeGroupVersion, &pipelinev1.PipelineRun{}, &pipelinev1.TaskRun{}, &pipelinev1.TaskRunList{})
+	cl := fake.NewFakeClientWithScheme(s, objs...)
+	reporter := git.NewMockStatusReporter()
+	checks := git.NewMockChecksReporter()
+	return cl, &ReconcileRunStatus{
+		client:       cl,
+		kind:         kind,
+		secretGetter: secrets.New(cl),
+		reporterFactory: func(*pollingv1.Repository, string, string) git.StatusReporter {
+			return reporter
+		},
+		checksFactory: func(string, git.GitHubApp) git.ChecksReporter {
+			return checks
+		},
+		log: logf.Log.WithName("testing"),
+	}
+}
@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/cel"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+// runTarget is a run to create for a change.
+type runTarget struct {
+	ns   string
+	opts pipelines.RunOptions
+}
+
+// runSelector identifies the runs that have been created for a target.
+type runSelector struct {
+	ns     string
+	kind   pipelines.RunKind
+	labels map[string]string
+}
+
+// runTargets returns the runs to create for the commit, this is a run for the
+// Repository's pipeline or task, or a run for each of the Repository's
+// Pipelines that match the commit.
+//
+// Pipelines with a NamespaceSelector have a run in each matching namespace.
+func (r *ReconcileRepository) runTargets(ctx context.Context, celctx *cel.Context, repo *pollingv1.Repository) ([]runTarget, error) {
+	if len(repo.Spec.Pipelines) == 0 {
+		ns, opts, err := makeRunOptions(celctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		if repo.RunsTask() {
+			return []runTarget{{ns: ns, opts: opts}}, nil
+		}
+		namespaces, err := r.pipelineNamespaces(ctx, repo, repo.Spec.Pipeline)
+		if err != nil {
+			return nil, err
+		}
+		return inNamespaces(repo, opts, namespaces), nil
+	}
+	targets := []runTarget{}
+	for _, p := range repo.Spec.Pipelines {
+		matched, err := matchesFilter(celctx, p.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate the filter for pipeline %q: %w", p.Name, err)
+		}
+		if !matched {
+			continue
+		}
+		opts, err := makeTargetRunOptions(celctx, repo, p)
+		if err != nil {
+			return nil, err
+		}
+		namespaces, err := r.pipelineNamespaces(ctx, repo, p.PipelineRef)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, inNamespaces(repo, opts, namespaces)...)
+	}
+	return targets, nil
+}
+
+// pipelineNamespaces returns the namespaces that the pipeline is executed in,
+// this is the namespace of the PipelineRef, or the Repository's namespace, or
+// all the namespaces that match the NamespaceSelector.
+func (r *ReconcileRepository) pipelineNamespaces(ctx context.Context, repo *pollingv1.Repository, ref pollingv1.PipelineRef) ([]string, error) {
+	if ref.NamespaceSelector == nil {
+		if ref.Namespace != "" {
+			return []string{ref.Namespace}, nil
+		}
+		return []string{repo.Namespace}, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(ref.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the namespaceSelector: %w", err)
+	}
+	list := &corev1.NamespaceList{}
+	if err := r.apiReader.List(ctx, list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("failed to list the namespaces: %w", err)
+	}
+	namespaces := []string{}
+	for _, ns := range list.Items {
+		namespaces = append(namespaces, ns.Name)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// inNamespaces returns a target for each of the namespaces with the options,
+// only the runs in the Repository's namespace are owned by the Repository.
+func inNamespaces(repo *pollingv1.Repository, opts pipelines.RunOptions, namespaces []string) []runTarget {
+	targets := []runTarget{}
+	for _, ns := range namespaces {
+		nsOpts := opts
+		nsOpts.Labels = make(map[string]string)
+		for k, v := range opts.Labels {
+			nsOpts.Labels[k] = v
+		}
+		nsOpts.OwnerReferences = nil
+		if ns == repo.Namespace {
+			nsOpts.OwnerReferences = []metav1.OwnerReference{ownerReference(repo)}
+		}
+		targets = append(targets, runTarget{ns: ns, opts: nsOpts})
+	}
+	return targets
+}
+
+// recordsLastRuns returns true if the runs for the Repository are recorded in
+// the LastRuns, rather than the LastRun, because more than one run can be
+// created for a change.
+func recordsLastRuns(repo *pollingv1.Repository) bool {
+	return len(repo.Spec.Pipelines) > 0 || (!repo.RunsTask() && repo.Spec.Pipeline.NamespaceSelector != nil)
+}
+
+// pipelineRefFor returns the PipelineRef for the named PipelineTarget, or the
+// Repository's PipelineRef if the name is empty.
+func pipelineRefFor(repo *pollingv1.Repository, name string) pollingv1.PipelineRef {
+	for _, p := range repo.Spec.Pipelines {
+		if p.Name == name {
+			return p.PipelineRef
+		}
+	}
+	return repo.Spec.Pipeline
+}
+
+// makeTargetRunOptions returns the options for executing one of the
+// Repository's Pipelines, the namespace is applied by inNamespaces.
+func makeTargetRunOptions(celctx *cel.Context, repo *pollingv1.Repository, target pollingv1.PipelineTarget) (pipelines.RunOptions, error) {
+	ref := target.PipelineRef
+	opts := pipelines.RunOptions{
+		Kind:               pipelines.PipelineRunKind,
+		Name:               ref.Name,
+		ServiceAccountName: ref.ServiceAccountName,
+		Resources:          ref.Resources,
+		Workspaces:         ref.Workspaces,
+		Template:           repo.Spec.PipelineRunTemplate,
+		Bundle:             ref.Bundle,
+		Resolver:           ref.Resolver,
+		ResolverParams:     ref.ResolverParams,
+	}
+	opts.Labels = createdRunLabels(repo)
+	opts.Labels[pollingv1.PipelineLabel] = target.Name
+	params, err := makeParams(celctx, ref.Params)
+	if err != nil {
+		return opts, err
+	}
+	opts.Params = params
+	opts.ObjectParams, err = makeObjectParams(celctx, ref.Params)
+	if err != nil {
+		return opts, err
+	}
+	opts.Workspaces = runWorkspaces(repo, opts.Workspaces, params)
+	return opts, nil
+}
+
+// matchesFilter returns true if the filter expression evaluates to true for
+// the commit, an empty filter matches all commits.
+func matchesFilter(celctx *cel.Context, filter string) (bool, error) {
+	if filter == "" {
+		return true, nil
+	}
+	v, err := celctx.Evaluate(filter)
+	if err != nil {
+		return false, err
+	}
+	matched, ok := v.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression %q did not evaluate to a bool", filter)
+	}
+	return matched, nil
+}
+
+// runSelectors returns the selectors for the runs created for the
+// Repository, there's one for each of the Repository's Pipelines, in each of
+// the namespaces that the Pipeline is executed in.
+func (r *ReconcileRepository) runSelectors(ctx context.Context, repo *pollingv1.Repository) ([]runSelector, error) {
+	if repo.RunsTask() {
+		return []runSelector{{ns: runNamespace(repo), kind: pipelines.TaskRunKind, labels: runLabels(repo)}}, nil
+	}
+	targets := []pollingv1.PipelineTarget{{PipelineRef: repo.Spec.Pipeline}}
+	if len(repo.Spec.Pipelines) > 0 {
+		targets = repo.Spec.Pipelines
+	}
+	selectors := []runSelector{}
+	for _, p := range targets {
+		namespaces, err := r.pipelineNamespaces(ctx, repo, p.PipelineRef)
+		if err != nil {
+			return nil, err
+		}
+		labels := runLabels(repo)
+		if p.Name != "" {
+			labels[pollingv1.PipelineLabel] = p.Name
+		}
+		for _, ns := range namespaces {
+			selectors = append(selectors, runSelector{ns: ns, kind: pipelines.PipelineRunKind, labels: labels})
+		}
+	}
+	return selectors, nil
+}
+
+// targetSelector returns the selector for the runs created for the same
+// target as the options.
+func targetSelector(ns string, opts pipelines.RunOptions) runSelector {
+	labels := map[string]string{
+		pollingv1.RepositoryLabel:          opts.Labels[pollingv1.RepositoryLabel],
+		pollingv1.RepositoryNamespaceLabel: opts.Labels[pollingv1.RepositoryNamespaceLabel],
+	}
+	if p, ok := opts.Labels[pollingv1.PipelineLabel]; ok {
+		labels[pollingv1.PipelineLabel] = p
+	}
+	return runSelector{ns: ns, kind: opts.Kind, labels: labels}
+}
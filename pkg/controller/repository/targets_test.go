@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/git"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+func TestReconcileRepositoryWithPipelines(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	scanNS := "test-scan-ns"
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline = pollingv1.PipelineRef{}
+		r.Spec.Pipelines = []pollingv1.PipelineTarget{
+			{
+				Name: "build",
+				PipelineRef: pollingv1.PipelineRef{
+					Name:               "build-pipeline",
+					ServiceAccountName: testServiceAccountName,
+					Params:             []pollingv1.Param{{Name: "sha", Expression: "commit.id"}},
+					Workspaces:         testWorkspaces,
+				},
+			},
+			{
+				Name: "scan",
+				PipelineRef: pollingv1.PipelineRef{
+					Name:      "scan-pipeline",
+					Namespace: scanNS,
+					Params:    []pollingv1.Param{{Name: "url", Expression: "repoURL"}},
+				},
+				Filter: "commit.id == 'main'",
+			},
+			{
+				Name:        "docs",
+				PipelineRef: pollingv1.PipelineRef{Name: "docs-pipeline"},
+				Filter:      "commit.id == 'docs'",
+			},
+		}
+	})
+	cl, r := makeReconciler(t, repo, repo,
+		makeTestPipeline("build-pipeline", testRepositoryNamespace, "sha"),
+		makeTestPipeline("scan-pipeline", scanNS, "url"))
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	runner := r.runner.(*pipelines.MockRunner)
+	runner.AssertRunOptions("build-pipeline", testRepositoryNamespace, pipelines.RunOptions{
+		Kind:               pipelines.PipelineRunKind,
+		Name:               "build-pipeline",
+		Labels:             withPipelineLabel(testCreatedRunLabels, "build"),
+		OwnerReferences:    testOwnerReferences,
+		ServiceAccountName: testServiceAccountName,
+		Params:             makeTestParams(map[string]string{"sha": "main"}),
+		Workspaces:         testWorkspaces,
+	})
+	runner.AssertRunOptions("scan-pipeline", scanNS, pipelines.RunOptions{
+		Kind:   pipelines.PipelineRunKind,
+		Name:   "scan-pipeline",
+		Labels: withPipelineLabel(testCreatedRunLabels, "scan"),
+		Params: makeTestParams(map[string]string{"url": testRepoURL}),
+	})
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), req.NamespacedName, loaded))
+	want := []pollingv1.RunStatus{
+		{Kind: "PipelineRun", Name: "build-pipeline", Namespace: testRepositoryNamespace, SHA: testCommitSHA, Pipeline: "build"},
+		{Kind: "PipelineRun", Name: "scan-pipeline", Namespace: scanNS, SHA: testCommitSHA, Pipeline: "scan"},
+	}
+	if diff := cmp.Diff(want, loaded.Status.LastRuns); diff != "" {
+		t.Fatalf("incorrect last runs:\n%s", diff)
+	}
+	if loaded.Status.LastRun != nil {
+		t.Fatalf("got LastRun %#v, want nil", loaded.Status.LastRun)
+	}
+}
+
+func TestReconcileRepositoryWithPipelinesPendingTrigger(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline = pollingv1.PipelineRef{}
+		r.Spec.Pipelines = []pollingv1.PipelineTarget{
+			{Name: "build", PipelineRef: pollingv1.PipelineRef{Name: "build-pipeline"}},
+			{Name: "scan", PipelineRef: pollingv1.PipelineRef{Name: "scan-pipeline"}},
+		}
+		r.Status.PollStatus = pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA, ETag: testCommitETag}
+		r.Status.PendingTrigger = &pollingv1.PendingTrigger{SHA: testCommitSHA, ID: "created-trigger"}
+	})
+	cl, r := makeReconciler(t, repo, repo,
+		makeTestPipeline("build-pipeline", testRepositoryNamespace),
+		makeTestPipeline("scan-pipeline", testRepositoryNamespace))
+	p := git.NewMockPoller()
+	p.AddMockResponse(
+		testRepo, pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA},
+		map[string]interface{}{"id": testRef},
+		pollingv1.PollStatus{Ref: testRef, SHA: testCommitSHA, ETag: testCommitETag})
+	r.pollerFactory = func(_ *pollingv1.Repository, endpoint, token string) git.CommitPoller {
+		return p
+	}
+	runner := r.runner.(*pipelines.MockRunner)
+	run := makeTestRun("build-run", "")
+	run.SetLabels(map[string]string{
+		pollingv1.RepositoryLabel:          testRepositoryName,
+		pollingv1.RepositoryNamespaceLabel: testRepositoryNamespace,
+		pollingv1.PipelineLabel:            "build",
+		pollingv1.TriggerIDLabel:           "created-trigger",
+	})
+	runner.AddRuns(run)
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), req.NamespacedName, loaded))
+	want := []pollingv1.RunStatus{
+		{Kind: "PipelineRun", Name: "build-run", Namespace: testRepositoryNamespace, SHA: testCommitSHA, Pipeline: "build"},
+		{Kind: "PipelineRun", Name: "scan-pipeline", Namespace: testRepositoryNamespace, SHA: testCommitSHA, Pipeline: "scan"},
+	}
+	if diff := cmp.Diff(want, loaded.Status.LastRuns); diff != "" {
+		t.Fatalf("incorrect last runs:\n%s", diff)
+	}
+	if loaded.Status.PendingTrigger != nil {
+		t.Fatalf("got PendingTrigger %#v, want it to be cleared", loaded.Status.PendingTrigger)
+	}
+}
+
+func TestReconcileRepositoryWithInvalidPipelineFilter(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline = pollingv1.PipelineRef{}
+		r.Spec.Pipelines = []pollingv1.PipelineTarget{
+			{Name: "build", PipelineRef: pollingv1.PipelineRef{Name: "build-pipeline"}, Filter: "commit.id"},
+		}
+	})
+	_, r := makeReconciler(t, repo, repo)
+
+	_, err := r.Reconcile(makeReconcileRequest())
+
+	if err == nil || !strings.Contains(err.Error(), `failed to evaluate the filter for pipeline "build"`) {
+		t.Fatalf("got error %v, want a filter error", err)
+	}
+	r.runner.(*pipelines.MockRunner).AssertNoRuns()
+}
+
+func TestReconcileRunStatusRecordsPipelineRuns(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.ReportStatus = &pollingv1.ReportStatus{Context: "ci"}
+		r.Status.LastRuns = []pollingv1.RunStatus{
+			{Kind: "PipelineRun", Name: "build-run", Namespace: testRepositoryNamespace, SHA: testCommitSHA, Pipeline: "build"},
+			{Kind: "PipelineRun", Name: "scan-run", Namespace: testRepositoryNamespace, SHA: testCommitSHA, Pipeline: "scan"},
+		}
+	})
+	pr := &pipelinev1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "scan-run",
+			Namespace: testRepositoryNamespace,
+			Labels:    withPipelineLabel(testCreatedRunLabels, "scan"),
+		},
+	}
+	pr.Status.Conditions = duckv1beta1.Conditions{
+		{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue, Reason: "Succeeded"},
+	}
+	cl, r := makeRunStatusReconciler(pipelines.PipelineRunKind, repo, pr)
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "scan-run", Namespace: testRepositoryNamespace}})
+	fatalIfError(t, err)
+
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), types.NamespacedName{Name: testRepositoryName, Namespace: testRepositoryNamespace}, loaded))
+	want := []pollingv1.RunStatus{
+		{Kind: "PipelineRun", Name: "build-run", Namespace: testRepositoryNamespace, SHA: testCommitSHA, Pipeline: "build"},
+		{Kind: "PipelineRun", Name: "scan-run", Namespace: testRepositoryNamespace, SHA: testCommitSHA, Pipeline: "scan", Succeeded: corev1.ConditionTrue, Reason: "Succeeded"},
+	}
+	if diff := cmp.Diff(want, loaded.Status.LastRuns); diff != "" {
+		t.Fatalf("incorrect last runs:\n%s", diff)
+	}
+	reporter := r.reporterFactory(repo, "", "").(*git.MockStatusReporter)
+	wantStatuses := []git.CommitStatus{
+		{State: git.StatusSuccess, Context: "ci/scan", Description: "The PipelineRun succeeded"},
+	}
+	if diff := cmp.Diff(wantStatuses, reporter.Statuses(testRepo, testCommitSHA)); diff != "" {
+		t.Fatalf("incorrect commit statuses:\n%s", diff)
+	}
+}
+
+func TestReconcileRepositoryWithNamespaceSelector(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Pipeline.NamespaceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{"team": "payments"},
+		}
+	})
+	cl, r := makeReconciler(t, repo, repo,
+		makeNamespace("tenant-b", "payments"),
+		makeNamespace("tenant-a", "payments"),
+		makeNamespace("tenant-c", "billing"),
+		makeTestPipeline(testPipelineName, "tenant-a", "one", "two"),
+		makeTestPipeline(testPipelineName, "tenant-b", "one", "two"))
+	req := makeReconcileRequest()
+
+	_, err := r.Reconcile(req)
+	fatalIfError(t, err)
+
+	runner := r.runner.(*pipelines.MockRunner)
+	for _, ns := range []string{"tenant-a", "tenant-b"} {
+		runner.AssertRunOptions(testPipelineName, ns, pipelines.RunOptions{
+			Kind:               pipelines.PipelineRunKind,
+			Name:               testPipelineName,
+			Labels:             testCreatedRunLabels,
+			ServiceAccountName: testServiceAccountName,
+			Params:             makeTestParams(map[string]string{"one": testRepoURL, "two": "main"}),
+			Resources:          testResources,
+			Workspaces:         testWorkspaces,
+		})
+	}
+	loaded := &pollingv1.Repository{}
+	fatalIfError(t, cl.Get(context.Background(), req.NamespacedName, loaded))
+	want := []pollingv1.RunStatus{
+		{Kind: "PipelineRun", Name: testPipelineName, Namespace: "tenant-a", SHA: testCommitSHA},
+		{Kind: "PipelineRun", Name: testPipelineName, Namespace: "tenant-b", SHA: testCommitSHA},
+	}
+	if diff := cmp.Diff(want, loaded.Status.LastRuns); diff != "" {
+		t.Fatalf("incorrect last runs:\n%s", diff)
+	}
+}
+
+func TestReportedName(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}}
+	nameTests := []struct {
+		name string
+		repo func(*pollingv1.Repository)
+		run  pollingv1.RunStatus
+		want string
+	}{
+		{"single pipeline", nil, pollingv1.RunStatus{Namespace: "test-ns"}, "ci"},
+		{
+			"namespace selector",
+			func(r *pollingv1.Repository) { r.Spec.Pipeline.NamespaceSelector = selector },
+			pollingv1.RunStatus{Namespace: "tenant-a"},
+			"ci/tenant-a",
+		},
+		{
+			"pipelines",
+			func(r *pollingv1.Repository) {
+				r.Spec.Pipelines = []pollingv1.PipelineTarget{{Name: "build"}}
+			},
+			pollingv1.RunStatus{Namespace: "test-ns", Pipeline: "build"},
+			"ci/build",
+		},
+		{
+			"pipelines with a namespace selector",
+			func(r *pollingv1.Repository) {
+				r.Spec.Pipelines = []pollingv1.PipelineTarget{
+					{Name: "build", PipelineRef: pollingv1.PipelineRef{NamespaceSelector: selector}},
+				}
+			},
+			pollingv1.RunStatus{Namespace: "tenant-a", Pipeline: "build"},
+			"ci/build/tenant-a",
+		},
+	}
+
+	for _, tt := range nameTests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := makeRepository()
+			if tt.repo != nil {
+				tt.repo(repo)
+			}
+			if got := reportedName("ci", repo, &tt.run); got != tt.want {
+				t.Fatalf("reportedName() got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func makeNamespace(name, team string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"team": team},
+		},
+	}
+}
+
+func withPipelineLabel(labels map[string]string, name string) map[string]string {
+	l := map[string]string{pollingv1.PipelineLabel: name}
+	for k, v := range labels {
+		l[k] = v
+	}
+	return l
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-logr/logr"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/cel"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/triggers"
+)
+
+// runTriggerTemplate renders the Repository's TriggerTemplate with the
+// bindings, and creates the resources that it declares.
+//
+// The resources are labelled with the ID of the PendingTrigger, which is only
+// cleared once all the resources have been created, if creating them fails,
+// the resources that were already created are skipped when it's retried.
+func (r *ReconcileRepository) runTriggerTemplate(ctx context.Context, logger logr.Logger, repo *pollingv1.Repository, celctx *cel.Context) error {
+	template := triggerTemplateName(repo)
+	params, err := makeBindings(celctx, repo.Spec.Bindings)
+	if err != nil {
+		logger.Error(err, "failed to parse the bindings")
+		return err
+	}
+	pending := r.pendingTrigger(repo)
+	opts := triggers.RunOptions{
+		Labels:       map[string]string{pollingv1.TriggerIDLabel: pending.ID},
+		SkipExisting: pending == repo.Status.PendingTrigger,
+	}
+	repo.Status.DeferredSHA = ""
+	repo.Status.PendingTrigger = pending
+	if err := r.updateStatus(ctx, logger, repo); err != nil {
+		return err
+	}
+	created, err := r.templateRunner.Run(ctx, template, params, opts)
+	if err != nil {
+		logger.Error(err, "failed to create the resources from the trigger template", "template", template, "sha", pending.SHA, "id", pending.ID)
+		return err
+	}
+	for _, c := range created {
+		logger.Info("Resource created from trigger template", "template", template, "kind", c.GetKind(), "name", c.GetName())
+	}
+	repo.Status.PendingTrigger = nil
+	return r.updateStatus(ctx, logger, repo)
+}
+
+// triggerTemplateName returns the name of the Repository's TriggerTemplate,
+// this defaults to the Repository's namespace.
+func triggerTemplateName(repo *pollingv1.Repository) types.NamespacedName {
+	template := types.NamespacedName{Name: repo.Spec.TriggerTemplate.Name, Namespace: repo.Spec.TriggerTemplate.Namespace}
+	if template.Namespace == "" {
+		template.Namespace = repo.Namespace
+	}
+	return template
+}
+
+// makeBindings evaluates the bindings, array and object values are JSON
+// encoded.
+func makeBindings(celctx *cel.Context, bindings []pollingv1.Param) (map[string]string, error) {
+	params, err := makeParams(celctx, bindings)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for _, p := range params {
+		if p.Value.Type == pipelinev1.ParamTypeArray {
+			b, err := json.Marshal(p.Value.ArrayVal)
+			if err != nil {
+				return nil, err
+			}
+			values[p.Name] = string(b)
+			continue
+		}
+		values[p.Name] = p.Value.StringVal
+	}
+	objects, err := makeObjectParams(celctx, bindings)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range objects {
+		b, err := json.Marshal(o.Value)
+		if err != nil {
+			return nil, err
+		}
+		values[o.Name] = string(b)
+	}
+	return values, nil
+}
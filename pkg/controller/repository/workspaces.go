@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+)
+
+// runWorkspaces returns the workspaces for a run.
+//
+// The $(params.name) references in the metadata of volumeClaimTemplates are
+// replaced with the values of the run's string params, and the
+// SourceWorkspace is added if it's configured and not already bound.
+func runWorkspaces(repo *pollingv1.Repository, bindings []pipelinev1.WorkspaceBinding, params []pipelinev1.Param) []pipelinev1.WorkspaceBinding {
+	var workspaces []pipelinev1.WorkspaceBinding
+	for _, b := range bindings {
+		if b.VolumeClaimTemplate != nil {
+			b.VolumeClaimTemplate = expandClaimTemplate(b.VolumeClaimTemplate, params)
+		}
+		workspaces = append(workspaces, b)
+	}
+	if ws := repo.Spec.SourceWorkspace; ws != nil && !hasWorkspace(workspaces, ws.GetName()) {
+		workspaces = append(workspaces, sourceWorkspace(repo, ws))
+	}
+	return workspaces
+}
+
+// expandClaimTemplate returns a copy of the claim with the param references
+// in the name, labels and annotations replaced.
+//
+// Tekton ignores the generateName of the template, so it's not expanded.
+func expandClaimTemplate(claim *corev1.PersistentVolumeClaim, params []pipelinev1.Param) *corev1.PersistentVolumeClaim {
+	expanded := claim.DeepCopy()
+	expanded.Name = claimName(replaceParams(claim.Name, params))
+	for k, v := range expanded.Labels {
+		expanded.Labels[k] = labelValue(replaceParams(v, params))
+	}
+	for k, v := range expanded.Annotations {
+		expanded.Annotations[k] = replaceParams(v, params)
+	}
+	return expanded
+}
+
+// sourceWorkspace returns a binding for the SourceWorkspace, Tekton creates a
+// volume from the template for each run, and deletes it along with the run.
+func sourceWorkspace(repo *pollingv1.Repository, ws *pollingv1.SourceWorkspace) pipelinev1.WorkspaceBinding {
+	claim := &corev1.PersistentVolumeClaim{
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: ws.GetSize()},
+			},
+		},
+	}
+	claim.Labels = createdRunLabels(repo)
+	if ws.StorageClassName != "" {
+		storageClassName := ws.StorageClassName
+		claim.Spec.StorageClassName = &storageClassName
+	}
+	return pipelinev1.WorkspaceBinding{Name: ws.GetName(), VolumeClaimTemplate: claim}
+}
+
+func hasWorkspace(workspaces []pipelinev1.WorkspaceBinding, name string) bool {
+	for _, w := range workspaces {
+		if w.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceParams replaces the $(params.name) references in s with the values
+// of the string params.
+func replaceParams(s string, params []pipelinev1.Param) string {
+	for _, p := range params {
+		if p.Value.Type == pipelinev1.ParamTypeString {
+			s = strings.ReplaceAll(s, fmt.Sprintf("$(params.%s)", p.Name), p.Value.StringVal)
+		}
+	}
+	return s
+}
+
+var invalidNameChars = regexp.MustCompile(`[^-a-z0-9.]`)
+
+// claimName converts s into a valid name for a volumeClaimTemplate, it's
+// lower-cased, invalid characters are replaced with "-", it's truncated to
+// leave space for the suffix that Tekton appends, and it must start and end
+// with an alphanumeric character.
+func claimName(s string) string {
+	return strings.Trim(nameChars(s, validation.DNS1123SubdomainMaxLength-claimNameSuffixLength), "-.")
+}
+
+// claimNameSuffixLength is the length of the suffix that Tekton appends to the
+// name of a volumeClaimTemplate, "-" and a 10 character hash of the workspace
+// and the run.
+const claimNameSuffixLength = 11
+
+func nameChars(s string, maxLength int) string {
+	v := invalidNameChars.ReplaceAllString(strings.ToLower(s), "-")
+	if len(v) > maxLength {
+		v = v[:maxLength]
+	}
+	return v
+}
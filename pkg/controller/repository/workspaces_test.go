@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+	"github.com/bigkevmcd/tekton-polling-operator/pkg/pipelines"
+)
+
+func TestReconcileRepositoryWithVolumeClaimTemplate(t *testing.T) {
+	logf.SetLogger(logf.ZapLogger(true))
+	claimSpec := corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+	}
+	repo := makeRepository(func(r *pollingv1.Repository) {
+		r.Spec.Ref = "feature/New"
+		r.Spec.Pipeline.Params = []pollingv1.Param{
+			{Name: "one", Expression: "repoURL"},
+			{Name: "two", Expression: "'Feature/New'"},
+		}
+		r.Spec.Pipeline.Workspaces = []pipelinev1.WorkspaceBinding{
+			{
+				Name: "cache",
+				VolumeClaimTemplate: &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "cache-$(params.two)",
+						Labels:      map[string]string{"branch": "$(params.two)"},
+						Annotations: map[string]string{"example.com/repo": "$(params.one)"},
+					},
+					Spec: claimSpec,
+				},
+			},
+		}
+	})
+	_, r := makeReconciler(t, repo, repo)
+
+	_, err := r.Reconcile(makeReconcileRequest())
+	fatalIfError(t, err)
+
+	want := []pipelinev1.WorkspaceBinding{
+		{
+			Name: "cache",
+			VolumeClaimTemplate: &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "cache-feature-new",
+					Labels:      map[string]string{"branch": "Feature-New"},
+					Annotations: map[string]string{"example.com/repo": testRepoURL},
+				},
+				Spec: claimSpec,
+			},
+		},
+	}
+	opts := r.runner.(*pipelines.MockRunner).RunOptions(testPipelineName, testRepositoryNamespace)
+	if diff := cmp.Diff(want, opts.Workspaces); diff != "" {
+		t.Fatalf("incorrect workspaces:\n%s", diff)
+	}
+	if name := repo.Spec.Pipeline.Workspaces[0].VolumeClaimTemplate.Name; name != "cache-$(params.two)" {
+		t.Fatalf("the Repository's template was modified: %q", name)
+	}
+}
+
+func TestExpandClaimTemplateWithTrailingSeparator(t *testing.T) {
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws-$(params.branch)"},
+	}
+	params := makeTestParams(map[string]string{"branch": "feature/"})
+
+	expanded := expandClaimTemplate(claim, params)
+
+	if expanded.Name != "ws-feature" {
+		t.Fatalf("got name %q, want %q", expanded.Name, "ws-feature")
+	}
+}
+
+func TestRunWorkspacesWithSourceWorkspace(t *testing.T) {
+	storageClass := "fast"
+	size := resource.MustParse("5Gi")
+	workspaceTests := []struct {
+		name     string
+		source   *pollingv1.SourceWorkspace
+		bindings []pipelinev1.WorkspaceBinding
+		want     []pipelinev1.WorkspaceBinding
+	}{
+		{"no source workspace", nil, testWorkspaces, testWorkspaces},
+		{
+			"default source workspace",
+			&pollingv1.SourceWorkspace{},
+			nil,
+			[]pipelinev1.WorkspaceBinding{
+				{Name: "source", VolumeClaimTemplate: makeSourceClaim(resource.MustParse("1Gi"), nil)},
+			},
+		},
+		{
+			"configured source workspace",
+			&pollingv1.SourceWorkspace{Name: "git-source", Size: &size, StorageClassName: storageClass},
+			testWorkspaces,
+			append(append([]pipelinev1.WorkspaceBinding{}, testWorkspaces...),
+				pipelinev1.WorkspaceBinding{Name: "git-source", VolumeClaimTemplate: makeSourceClaim(size, &storageClass)}),
+		},
+		{
+			"source workspace already bound",
+			&pollingv1.SourceWorkspace{Name: "test-workspace"},
+			testWorkspaces,
+			testWorkspaces,
+		},
+	}
+
+	for _, tt := range workspaceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := makeRepository(func(r *pollingv1.Repository) {
+				r.Spec.SourceWorkspace = tt.source
+				r.Status.PollStatus.SHA = testCommitSHA
+			})
+
+			got := runWorkspaces(repo, tt.bindings, nil)
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("runWorkspaces() failed:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClaimName(t *testing.T) {
+	nameTests := []struct {
+		in   string
+		want string
+	}{
+		{"cache", "cache"},
+		{"cache-Feature/New-", "cache-feature-new"},
+		{"-leading", "leading"},
+		{"ws-feature/", "ws-feature"},
+		{"ws-v1.", "ws-v1"},
+		{strings.Repeat("a", 241) + "/b", strings.Repeat("a", 241)},
+		{strings.Repeat("a", 260), strings.Repeat("a", 242)},
+	}
+
+	for _, tt := range nameTests {
+		if v := claimName(tt.in); v != tt.want {
+			t.Errorf("claimName(%q) got %q, want %q", tt.in, v, tt.want)
+		}
+	}
+}
+
+func makeSourceClaim(size resource.Quantity, storageClass *string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				pollingv1.RepositoryLabel:          testRepositoryName,
+				pollingv1.RepositoryNamespaceLabel: testRepositoryNamespace,
+				pollingv1.SHALabel:                 testCommitSHA,
+				pollingv1.RefLabel:                 testRef,
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+			StorageClassName: storageClass,
+		},
+	}
+}
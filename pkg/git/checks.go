@@ -0,0 +1,294 @@
+package git
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const checksMediaType = "application/vnd.github.v3+json"
+
+// tokenExpiryMargin is how long before it expires that a cached installation
+// token is replaced, so that it doesn't expire during a request.
+const tokenExpiryMargin = time.Minute * 5
+
+// GitHubApp is the identity of a GitHub App installation, this is used to
+// authenticate requests to the Checks API, which isn't available with
+// personal access tokens.
+type GitHubApp struct {
+	AppID          int64
+	InstallationID int64
+	// PrivateKey is the PEM encoded RSA private key for the App.
+	PrivateKey []byte
+}
+
+// InstallationTokens caches installation tokens until they expire, so that a
+// token isn't requested for each check run that's reported.
+type InstallationTokens struct {
+	mu     sync.Mutex
+	tokens map[installationKey]installationToken
+}
+
+type installationKey struct {
+	endpoint       string
+	appID          int64
+	installationID int64
+}
+
+type installationToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// NewInstallationTokens creates and returns a new, empty InstallationTokens.
+func NewInstallationTokens() *InstallationTokens {
+	return &InstallationTokens{tokens: make(map[installationKey]installationToken)}
+}
+
+func (t *InstallationTokens) get(key installationKey, now time.Time) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cached, ok := t.tokens[key]
+	if !ok || !now.Before(cached.expiresAt.Add(-tokenExpiryMargin)) {
+		return "", false
+	}
+	return cached.token, true
+}
+
+func (t *InstallationTokens) put(key installationKey, token installationToken) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens[key] = token
+}
+
+// GitHubChecks creates and updates check runs with the GitHub Checks API.
+type GitHubChecks struct {
+	client   *http.Client
+	endpoint string
+	app      GitHubApp
+	tokens   *InstallationTokens
+	now      func() time.Time
+}
+
+// NewGitHubChecks creates and returns a new ChecksReporter that authenticates
+// as the GitHub App installation, with installation tokens cached in tokens.
+func NewGitHubChecks(c *http.Client, endpoint string, app GitHubApp, tokens *InstallationTokens) *GitHubChecks {
+	return &GitHubChecks{client: c, endpoint: endpoint, app: app, tokens: tokens, now: time.Now}
+}
+
+// CreateCheckRun is an implementation of the ChecksReporter interface.
+func (g GitHubChecks) CreateCheckRun(repo string, run CheckRun) (int64, error) {
+	requestURL, err := makeGitHubPathURL(g.endpoint, "repos", repo, "check-runs")
+	if err != nil {
+		return 0, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := g.do("POST", requestURL, makeGitHubCheckRun(run), &created); err != nil {
+		return 0, fmt.Errorf("failed to create the check run: %w", err)
+	}
+	return created.ID, nil
+}
+
+// UpdateCheckRun is an implementation of the ChecksReporter interface.
+func (g GitHubChecks) UpdateCheckRun(repo string, id int64, run CheckRun) error {
+	requestURL, err := makeGitHubPathURL(g.endpoint, "repos", repo, "check-runs", strconv.FormatInt(id, 10))
+	if err != nil {
+		return fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	if err := g.do("PATCH", requestURL, makeGitHubCheckRun(run), nil); err != nil {
+		return fmt.Errorf("failed to update the check run: %w", err)
+	}
+	return nil
+}
+
+// do makes a request with an installation token, decoding the response into
+// out if it's not nil.
+func (g GitHubChecks) do(method, requestURL string, body, out interface{}) error {
+	token, err := g.installationToken()
+	if err != nil {
+		return err
+	}
+	return g.request(method, requestURL, fmt.Sprintf("token %s", token), body, out)
+}
+
+// installationToken exchanges a JWT signed with the App's private key for a
+// token for the installation, the token is cached until shortly before it
+// expires.
+func (g GitHubChecks) installationToken() (string, error) {
+	key := installationKey{endpoint: g.endpoint, appID: g.app.AppID, installationID: g.app.InstallationID}
+	if token, ok := g.tokens.get(key, g.now()); ok {
+		return token, nil
+	}
+	jwt, err := g.appJWT()
+	if err != nil {
+		return "", fmt.Errorf("failed to create the GitHub App JWT: %w", err)
+	}
+	requestURL, err := makeGitHubPathURL(g.endpoint, "app", "installations", strconv.FormatInt(g.app.InstallationID, 10), "access_tokens")
+	if err != nil {
+		return "", fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := g.request("POST", requestURL, fmt.Sprintf("Bearer %s", jwt), nil, &token); err != nil {
+		return "", fmt.Errorf("failed to get an installation token: %w", err)
+	}
+	g.tokens.put(key, installationToken{token: token.Token, expiresAt: token.ExpiresAt})
+	return token.Token, nil
+}
+
+func (g GitHubChecks) request(method, requestURL, auth string, body, out interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode the request body: %w", err)
+		}
+		reqBody = b
+	}
+	req, err := http.NewRequest(method, requestURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", checksMediaType)
+	req.Header.Add("Authorization", auth)
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("server error: %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("failed to decode response body: %w", err)
+	}
+	return nil
+}
+
+// appJWT returns a JWT that authenticates as the GitHub App, signed with
+// RS256, this is valid for 9 minutes, with the issued time set in the past to
+// allow for clock drift.
+func (g GitHubChecks) appJWT() (string, error) {
+	key, err := parseRSAPrivateKey(g.app.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	now := g.now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(time.Minute * 9).Unix(),
+		"iss": strconv.FormatInt(g.app.AppID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// parseRSAPrivateKey parses PKCS#1 and PKCS#8 PEM encoded RSA keys, GitHub
+// provides App keys in PKCS#1 format.
+func parseRSAPrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("failed to decode the private key PEM")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the private key is not an RSA key")
+	}
+	return key, nil
+}
+
+func makeGitHubPathURL(endpoint string, elem ...string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	parsed.Path = path.Join(elem...)
+	return parsed.String(), nil
+}
+
+func makeGitHubCheckRun(run CheckRun) githubCheckRun {
+	cr := githubCheckRun{
+		Name:       run.Name,
+		HeadSHA:    run.HeadSHA,
+		DetailsURL: run.DetailsURL,
+		ExternalID: run.ExternalID,
+		Status:     string(run.Status),
+		Conclusion: string(run.Conclusion),
+	}
+	if !run.StartedAt.IsZero() {
+		cr.StartedAt = run.StartedAt.UTC().Format(time.RFC3339)
+	}
+	if !run.CompletedAt.IsZero() {
+		cr.CompletedAt = run.CompletedAt.UTC().Format(time.RFC3339)
+	}
+	if run.Title != "" || run.Summary != "" {
+		cr.Output = &githubCheckRunOutput{Title: run.Title, Summary: run.Summary, Text: run.Text}
+	}
+	return cr
+}
+
+type githubCheckRun struct {
+	Name        string                `json:"name,omitempty"`
+	HeadSHA     string                `json:"head_sha,omitempty"`
+	DetailsURL  string                `json:"details_url,omitempty"`
+	ExternalID  string                `json:"external_id,omitempty"`
+	Status      string                `json:"status,omitempty"`
+	Conclusion  string                `json:"conclusion,omitempty"`
+	StartedAt   string                `json:"started_at,omitempty"`
+	CompletedAt string                `json:"completed_at,omitempty"`
+	Output      *githubCheckRunOutput `json:"output,omitempty"`
+}
+
+type githubCheckRunOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Text    string `json:"text,omitempty"`
+}
@@ -0,0 +1,265 @@
+package git
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	testAppID          = 1234
+	testInstallationID = 5678
+	testInstallToken   = "test-installation-token"
+)
+
+var _ ChecksReporter = (*GitHubChecks)(nil)
+
+func TestGitHubChecksCreateCheckRun(t *testing.T) {
+	key := generateKey(t)
+	fake := newFakeChecksAPI(t, &key.PublicKey)
+	as := httptest.NewTLSServer(fake)
+	t.Cleanup(as.Close)
+	g := NewGitHubChecks(as.Client(), as.URL, GitHubApp{
+		AppID:          testAppID,
+		InstallationID: testInstallationID,
+		PrivateKey:     encodeKey(key),
+	}, NewInstallationTokens())
+	started := time.Date(2020, time.October, 7, 12, 0, 0, 0, time.UTC)
+
+	id, err := g.CreateCheckRun("testing/repo", CheckRun{
+		Name:       "tekton",
+		HeadSHA:    "7638417db6d59f3c431d3e1f261cc637155684cd",
+		DetailsURL: "https://dashboard.example.com/test-run",
+		ExternalID: "test-ns/test-run",
+		Status:     CheckInProgress,
+		StartedAt:  started,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 42 {
+		t.Fatalf("CreateCheckRun() got ID %d, want 42", id)
+	}
+	want := map[string]interface{}{
+		"name":        "tekton",
+		"head_sha":    "7638417db6d59f3c431d3e1f261cc637155684cd",
+		"details_url": "https://dashboard.example.com/test-run",
+		"external_id": "test-ns/test-run",
+		"status":      "in_progress",
+		"started_at":  "2020-10-07T12:00:00Z",
+	}
+	if diff := cmp.Diff(want, fake.checkRuns[42]); diff != "" {
+		t.Fatalf("incorrect check run created:\n%s", diff)
+	}
+}
+
+func TestGitHubChecksUpdateCheckRun(t *testing.T) {
+	key := generateKey(t)
+	fake := newFakeChecksAPI(t, &key.PublicKey)
+	fake.checkRuns[42] = map[string]interface{}{"name": "tekton"}
+	as := httptest.NewTLSServer(fake)
+	t.Cleanup(as.Close)
+	g := NewGitHubChecks(as.Client(), as.URL, GitHubApp{
+		AppID:          testAppID,
+		InstallationID: testInstallationID,
+		PrivateKey:     encodeKey(key),
+	}, NewInstallationTokens())
+
+	err := g.UpdateCheckRun("testing/repo", 42, CheckRun{
+		Status:      CheckCompleted,
+		Conclusion:  CheckFailure,
+		CompletedAt: time.Date(2020, time.October, 7, 12, 5, 0, 0, time.UTC),
+		Title:       "The PipelineRun failed",
+		Summary:     "| Task | Status |",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"status":       "completed",
+		"conclusion":   "failure",
+		"completed_at": "2020-10-07T12:05:00Z",
+		"output": map[string]interface{}{
+			"title":   "The PipelineRun failed",
+			"summary": "| Task | Status |",
+		},
+	}
+	if diff := cmp.Diff(want, fake.checkRuns[42]); diff != "" {
+		t.Fatalf("incorrect check run update:\n%s", diff)
+	}
+}
+
+func TestGitHubChecksWithInvalidKey(t *testing.T) {
+	g := NewGitHubChecks(http.DefaultClient, "https://api.github.com", GitHubApp{
+		AppID:          testAppID,
+		InstallationID: testInstallationID,
+		PrivateKey:     []byte("not a key"),
+	}, NewInstallationTokens())
+
+	_, err := g.CreateCheckRun("testing/repo", CheckRun{Name: "tekton"})
+	if err == nil || !strings.Contains(err.Error(), "failed to decode the private key PEM") {
+		t.Fatalf("got error %v, want a private key error", err)
+	}
+}
+
+func TestGitHubChecksCachesInstallationTokens(t *testing.T) {
+	key := generateKey(t)
+	fake := newFakeChecksAPI(t, &key.PublicKey)
+	fake.checkRuns[42] = map[string]interface{}{"name": "tekton"}
+	as := httptest.NewTLSServer(fake)
+	t.Cleanup(as.Close)
+	tokens := NewInstallationTokens()
+	app := GitHubApp{
+		AppID:          testAppID,
+		InstallationID: testInstallationID,
+		PrivateKey:     encodeKey(key),
+	}
+	now := fake.expiresAt.Add(-time.Hour)
+	newChecks := func() *GitHubChecks {
+		g := NewGitHubChecks(as.Client(), as.URL, app, tokens)
+		g.now = func() time.Time { return now }
+		return g
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := newChecks().UpdateCheckRun("testing/repo", 42, CheckRun{Status: CheckInProgress}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fake.tokenRequests != 1 {
+		t.Fatalf("got %d token requests, want 1", fake.tokenRequests)
+	}
+
+	// The token is replaced shortly before it expires.
+	now = fake.expiresAt.Add(-time.Minute)
+	if err := newChecks().UpdateCheckRun("testing/repo", 42, CheckRun{Status: CheckInProgress}); err != nil {
+		t.Fatal(err)
+	}
+	if fake.tokenRequests != 2 {
+		t.Fatalf("got %d token requests, want 2", fake.tokenRequests)
+	}
+}
+
+// fakeChecksAPI is a fake of the GitHub endpoints for installation tokens and
+// check runs.
+type fakeChecksAPI struct {
+	t             *testing.T
+	key           *rsa.PublicKey
+	checkRuns     map[int64]map[string]interface{}
+	expiresAt     time.Time
+	tokenRequests int
+}
+
+func newFakeChecksAPI(t *testing.T, key *rsa.PublicKey) *fakeChecksAPI {
+	return &fakeChecksAPI{
+		t:         t,
+		key:       key,
+		checkRuns: make(map[int64]map[string]interface{}),
+		expiresAt: time.Date(2020, time.October, 7, 13, 0, 0, 0, time.UTC),
+	}
+}
+
+func (f *fakeChecksAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/app/installations/5678/access_tokens":
+		if !f.validJWT(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		f.tokenRequests++
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"token":      testInstallToken,
+			"expires_at": f.expiresAt.Format(time.RFC3339),
+		})
+	case r.Method == http.MethodPost && r.URL.Path == "/repos/testing/repo/check-runs":
+		body, ok := f.decodeBody(w, r)
+		if !ok {
+			return
+		}
+		f.checkRuns[42] = body
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]int64{"id": 42})
+	case r.Method == http.MethodPatch && r.URL.Path == "/repos/testing/repo/check-runs/42":
+		if _, ok := f.checkRuns[42]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, ok := f.decodeBody(w, r)
+		if !ok {
+			return
+		}
+		f.checkRuns[42] = body
+		json.NewEncoder(w).Encode(map[string]int64{"id": 42})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeChecksAPI) decodeBody(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+	if r.Header.Get("Authorization") != "token "+testInstallToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+	if r.Header.Get("Accept") != checksMediaType {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+func (f *fakeChecksAPI) validJWT(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(f.key, crypto.SHA256, digest[:], sig); err != nil {
+		return false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return false
+	}
+	return claims["iss"] == "1234"
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encodeKey(key *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
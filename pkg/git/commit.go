@@ -0,0 +1,60 @@
+package git
+
+import (
+	"time"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+)
+
+// CommitInfo is the provider-independent description of a polled commit.
+type CommitInfo struct {
+	SHA     string
+	Author  string
+	Message string
+	// Timestamp is when the commit was committed, this is zero if the
+	// provider didn't return it.
+	Timestamp time.Time
+}
+
+// NormaliseCommit returns the CommitInfo for a commit polled from the
+// provider, fields that are missing from the commit are left empty.
+func NormaliseCommit(repoType pollingv1.RepoType, c Commit) CommitInfo {
+	if repoType == pollingv1.GitLab {
+		return CommitInfo{
+			SHA:       stringField(c, "id"),
+			Author:    stringField(c, "author_name"),
+			Message:   stringField(c, "message"),
+			Timestamp: timeField(c, "committed_date"),
+		}
+	}
+	// The commits API nests the Git commit in "commit", the Git database API
+	// returns it at the top-level.
+	details := c
+	if nested, ok := c["commit"].(map[string]interface{}); ok {
+		details = nested
+	}
+	info := CommitInfo{
+		SHA:     stringField(c, "sha"),
+		Message: stringField(details, "message"),
+	}
+	if author, ok := details["author"].(map[string]interface{}); ok {
+		info.Author = stringField(author, "name")
+	}
+	if committer, ok := details["committer"].(map[string]interface{}); ok {
+		info.Timestamp = timeField(committer, "date")
+	}
+	return info
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func timeField(m map[string]interface{}, key string) time.Time {
+	t, err := time.Parse(time.RFC3339, stringField(m, key))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
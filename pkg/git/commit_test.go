@@ -0,0 +1,85 @@
+package git
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+)
+
+func TestNormaliseCommit(t *testing.T) {
+	var gitlabCommits []Commit
+	if err := json.Unmarshal(mustReadFile(t, "testdata/gitlab_commit.json"), &gitlabCommits); err != nil {
+		t.Fatal(err)
+	}
+	var githubCommit Commit
+	if err := json.Unmarshal(mustReadFile(t, "testdata/github_commit.json"), &githubCommit); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		repoType pollingv1.RepoType
+		commit   Commit
+		want     CommitInfo
+	}{
+		{
+			name:     "github git commit",
+			repoType: pollingv1.GitHub,
+			commit:   githubCommit,
+			want: CommitInfo{
+				SHA:       "7638417db6d59f3c431d3e1f261cc637155684cd",
+				Author:    "Monalisa Octocat",
+				Message:   "added readme, because im a good github citizen",
+				Timestamp: time.Date(2014, time.November, 7, 22, 1, 45, 0, time.UTC),
+			},
+		},
+		{
+			name:     "github commit",
+			repoType: pollingv1.GitHub,
+			commit: Commit{
+				"sha": "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+				"commit": map[string]interface{}{
+					"author":    map[string]interface{}{"name": "Monalisa Octocat", "date": "2011-04-14T16:00:49Z"},
+					"committer": map[string]interface{}{"name": "The Octocat", "date": "2011-04-15T16:00:49Z"},
+					"message":   "Fix all the bugs",
+				},
+			},
+			want: CommitInfo{
+				SHA:       "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+				Author:    "Monalisa Octocat",
+				Message:   "Fix all the bugs",
+				Timestamp: time.Date(2011, time.April, 15, 16, 0, 49, 0, time.UTC),
+			},
+		},
+		{
+			name:     "gitlab commit",
+			repoType: pollingv1.GitLab,
+			commit:   gitlabCommits[0],
+			want: CommitInfo{
+				SHA:       "ed899a2f4b50b4370feeea94676502b42383c746",
+				Author:    "Example User",
+				Message:   "Replace sanitize with escape once",
+				Timestamp: time.Date(2012, time.September, 20, 8, 50, 22, 0, time.UTC),
+			},
+		},
+		{
+			name:     "missing fields",
+			repoType: pollingv1.GitHub,
+			commit:   Commit{"id": "main"},
+			want:     CommitInfo{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NormaliseCommit(tt.repoType, tt.commit)
+			if diff := cmp.Diff(tt.want, got, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+				t.Fatalf("NormaliseCommit() failed:\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package git
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -67,6 +68,41 @@ func (g GitHubPoller) Poll(repo string, pr pollingv1.PollStatus) (pollingv1.Poll
 	return pollingv1.PollStatus{Ref: pr.Ref, SHA: gc["sha"].(string), ETag: resp.Header.Get("ETag")}, gc, nil
 }
 
+// ReportStatus is an implementation of the StatusReporter interface, it
+// creates a commit status with the GitHub statuses API.
+func (g GitHubPoller) ReportStatus(repo, sha string, status CommitStatus) error {
+	requestURL, err := makeGitHubStatusURL(g.endpoint, repo, sha)
+	if err != nil {
+		return fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	body, err := json.Marshal(githubStatus{
+		State:       string(status.State),
+		TargetURL:   status.TargetURL,
+		Description: status.Description,
+		Context:     status.Context,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode the status: %w", err)
+	}
+	req, err := http.NewRequest("POST", requestURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to make the request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if g.authToken != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("token %s", g.authToken))
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to create the commit status: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("server error: %d", resp.StatusCode)
+	}
+	return nil
+}
+
 func makeGitHubURL(endpoint, repo, ref string) (string, error) {
 	parsed, err := url.Parse(endpoint)
 	if err != nil {
@@ -76,6 +112,22 @@ func makeGitHubURL(endpoint, repo, ref string) (string, error) {
 	return parsed.String(), nil
 }
 
+func makeGitHubStatusURL(endpoint, repo, sha string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	parsed.Path = path.Join("repos", repo, "statuses", sha)
+	return parsed.String(), nil
+}
+
+type githubStatus struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context,omitempty"`
+}
+
 type githubCommit struct {
 	SHA string `json:"sha"`
 }
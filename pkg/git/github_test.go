@@ -1,18 +1,22 @@
 package git
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+
 	pollingv1alpha1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
 )
 
 const testToken = "test12345"
 
 var _ CommitPoller = (*GitHubPoller)(nil)
+var _ StatusReporter = (*GitHubPoller)(nil)
 
 func TestNewGitHubPoller(t *testing.T) {
 	newTests := []struct {
@@ -117,6 +121,60 @@ func TestGitHubWithNoAuthentication(t *testing.T) {
 
 // makeAPIServer is used during testing to create an HTTP server to return
 // fixtures if the request matches.
+func TestGitHubReportStatus(t *testing.T) {
+	var got map[string]string
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/testing/repo/statuses/7638417db6d59f3c431d3e1f261cc637155684cd" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if auth := r.Header.Get("Authorization"); auth != fmt.Sprintf("token %s", testToken) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+	err := g.ReportStatus("testing/repo", "7638417db6d59f3c431d3e1f261cc637155684cd", CommitStatus{
+		State:       StatusSuccess,
+		Context:     "tekton",
+		Description: "The PipelineRun succeeded",
+		TargetURL:   "https://dashboard.example.com/test-run",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"state":       "success",
+		"context":     "tekton",
+		"description": "The PipelineRun succeeded",
+		"target_url":  "https://dashboard.example.com/test-run",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("incorrect status reported:\n%s", diff)
+	}
+}
+
+func TestGitHubReportStatusWithError(t *testing.T) {
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+	err := g.ReportStatus("testing/repo", "7638417db6d59f3c431d3e1f261cc637155684cd", CommitStatus{State: StatusPending})
+	if err == nil || err.Error() != "server error: 422" {
+		t.Fatalf("got error %v, want server error", err)
+	}
+}
+
 func makeGitHubAPIServer(t *testing.T, authToken, wantPath, etag string, response []byte) *httptest.Server {
 	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != wantPath {
@@ -61,6 +61,57 @@ func (g GitLabPoller) Poll(repo string, pr pollingv1.PollStatus) (pollingv1.Poll
 	return pollingv1.PollStatus{Ref: pr.Ref, SHA: commit["id"].(string), ETag: resp.Header.Get("ETag")}, commit, nil
 }
 
+// ReportStatus is an implementation of the StatusReporter interface, it
+// creates a commit status with the GitLab commit statuses API.
+func (g GitLabPoller) ReportStatus(repo, sha string, status CommitStatus) error {
+	values := url.Values{
+		"state": []string{gitlabState(status.State)},
+	}
+	if status.Context != "" {
+		values.Set("name", status.Context)
+	}
+	if status.TargetURL != "" {
+		values.Set("target_url", status.TargetURL)
+	}
+	if status.Description != "" {
+		values.Set("description", status.Description)
+	}
+	req, err := http.NewRequest("POST", makeGitLabStatusURL(g.endpoint, repo, sha), strings.NewReader(values.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to make the request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	if g.authToken != "" {
+		req.Header.Add("Private-Token", g.authToken)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to create the commit status: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("server error: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// gitlabState converts the state to a GitLab commit status state, GitLab
+// distinguishes between pending and running, and uses "failed" for failures.
+func gitlabState(s StatusState) string {
+	switch s {
+	case StatusPending:
+		return "running"
+	case StatusFailure:
+		return "failed"
+	}
+	return string(s)
+}
+
+func makeGitLabStatusURL(endpoint, repo, sha string) string {
+	return fmt.Sprintf("%s/api/v4/projects/%s/statuses/%s",
+		endpoint, strings.Replace(repo, "/", "%2F", -1), sha)
+}
+
 func makeGitLabURL(endpoint, repo, ref string) string {
 	values := url.Values{
 		"ref_name": []string{ref},
@@ -3,12 +3,16 @@ package git
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+
 	pollingv1alpha1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
 )
 
 var _ CommitPoller = (*GitLabPoller)(nil)
+var _ StatusReporter = (*GitLabPoller)(nil)
 
 func TestNewGitLabPoller(t *testing.T) {
 	newTests := []struct {
@@ -115,6 +119,48 @@ func TestGitLabWithNoAuthentication(t *testing.T) {
 
 // makeAPIServer is used during testing to create an HTTP server to return
 // fixtures if the request matches.
+func TestGitLabReportStatus(t *testing.T) {
+	var got url.Values
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.RawPath != "/api/v4/projects/testing%2Frepo/statuses/ed899a2f4b50b4370feeea94676502b42383c746" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if auth := r.Header.Get("Private-Token"); auth != testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		got = r.PostForm
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(as.Close)
+	g := NewGitLabPoller(as.Client(), as.URL, testToken)
+
+	err := g.ReportStatus("testing/repo", "ed899a2f4b50b4370feeea94676502b42383c746", CommitStatus{
+		State:       StatusFailure,
+		Context:     "tekton",
+		Description: "The PipelineRun failed",
+		TargetURL:   "https://dashboard.example.com/test-run",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := url.Values{
+		"state":       []string{"failed"},
+		"name":        []string{"tekton"},
+		"description": []string{"The PipelineRun failed"},
+		"target_url":  []string{"https://dashboard.example.com/test-run"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("incorrect status reported:\n%s", diff)
+	}
+}
+
 func makeGitLabAPIServer(t *testing.T, authToken, wantPath, wantRef, etag string, response []byte) *httptest.Server {
 	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != wantPath {
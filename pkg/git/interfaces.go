@@ -1,6 +1,8 @@
 package git
 
 import (
+	"time"
+
 	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
 )
 
@@ -12,3 +14,69 @@ type Commit map[string]interface{}
 type CommitPoller interface {
 	Poll(repo string, ps pollingv1.PollStatus) (pollingv1.PollStatus, Commit, error)
 }
+
+// StatusState is the state of a commit status.
+type StatusState string
+
+const (
+	StatusPending StatusState = "pending"
+	StatusSuccess StatusState = "success"
+	StatusFailure StatusState = "failure"
+)
+
+// CommitStatus is the status of a commit, as reported to the upstream Git
+// hosting service.
+type CommitStatus struct {
+	State       StatusState
+	Context     string
+	Description string
+	TargetURL   string
+}
+
+// StatusReporter implementations can report the status of a commit to an
+// upstream Git hosting service.
+type StatusReporter interface {
+	ReportStatus(repo, sha string, status CommitStatus) error
+}
+
+// CheckStatus is the status of a check run.
+type CheckStatus string
+
+const (
+	CheckInProgress CheckStatus = "in_progress"
+	CheckCompleted  CheckStatus = "completed"
+)
+
+// CheckConclusion is the conclusion of a completed check run.
+type CheckConclusion string
+
+const (
+	CheckSuccess   CheckConclusion = "success"
+	CheckFailure   CheckConclusion = "failure"
+	CheckCancelled CheckConclusion = "cancelled"
+)
+
+// CheckRun is a check run for a commit.
+type CheckRun struct {
+	Name       string
+	HeadSHA    string
+	DetailsURL string
+	// ExternalID identifies the run that the check run reports.
+	ExternalID  string
+	Status      CheckStatus
+	Conclusion  CheckConclusion
+	StartedAt   time.Time
+	CompletedAt time.Time
+	// Title, Summary and Text are the output of the check run, the Summary
+	// and Text are Markdown.
+	Title   string
+	Summary string
+	Text    string
+}
+
+// ChecksReporter implementations can create and update check runs for
+// commits.
+type ChecksReporter interface {
+	CreateCheckRun(repo string, run CheckRun) (int64, error)
+	UpdateCheckRun(repo string, id int64, run CheckRun) error
+}
@@ -1,12 +1,15 @@
 package git
 
 import (
+	"fmt"
 	"strings"
 
 	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
 )
 
 var _ CommitPoller = (*MockPoller)(nil)
+var _ StatusReporter = (*MockStatusReporter)(nil)
+var _ ChecksReporter = (*MockChecksReporter)(nil)
 
 // NewMockPoller creates and returns a new mock Git poller.
 func NewMockPoller() *MockPoller {
@@ -47,3 +50,80 @@ func (m *MockPoller) FailWithError(err error) {
 func mockKey(repo string, ps pollingv1.PollStatus) string {
 	return strings.Join([]string{repo, ps.Ref, ps.SHA, ps.ETag}, ":")
 }
+
+// NewMockStatusReporter creates and returns a new mock StatusReporter.
+func NewMockStatusReporter() *MockStatusReporter {
+	return &MockStatusReporter{statuses: make(map[string][]CommitStatus)}
+}
+
+// MockStatusReporter records the reported statuses.
+type MockStatusReporter struct {
+	reportError error
+	statuses    map[string][]CommitStatus
+}
+
+// ReportStatus is an implementation of the StatusReporter interface.
+func (m *MockStatusReporter) ReportStatus(repo, sha string, status CommitStatus) error {
+	if m.reportError != nil {
+		return m.reportError
+	}
+	k := repo + ":" + sha
+	m.statuses[k] = append(m.statuses[k], status)
+	return nil
+}
+
+// Statuses returns the statuses reported for the commit in the repo.
+func (m *MockStatusReporter) Statuses(repo, sha string) []CommitStatus {
+	return m.statuses[repo+":"+sha]
+}
+
+// FailWithError configures the reporter to return errors.
+func (m *MockStatusReporter) FailWithError(err error) {
+	m.reportError = err
+}
+
+// NewMockChecksReporter creates and returns a new mock ChecksReporter.
+func NewMockChecksReporter() *MockChecksReporter {
+	return &MockChecksReporter{checkRuns: make(map[int64]CheckRun)}
+}
+
+// MockChecksReporter records the created and updated check runs.
+type MockChecksReporter struct {
+	reportError error
+	nextID      int64
+	checkRuns   map[int64]CheckRun
+}
+
+// CreateCheckRun is an implementation of the ChecksReporter interface, the
+// check runs are numbered from 1.
+func (m *MockChecksReporter) CreateCheckRun(repo string, run CheckRun) (int64, error) {
+	if m.reportError != nil {
+		return 0, m.reportError
+	}
+	m.nextID++
+	m.checkRuns[m.nextID] = run
+	return m.nextID, nil
+}
+
+// UpdateCheckRun is an implementation of the ChecksReporter interface.
+func (m *MockChecksReporter) UpdateCheckRun(repo string, id int64, run CheckRun) error {
+	if m.reportError != nil {
+		return m.reportError
+	}
+	if _, ok := m.checkRuns[id]; !ok {
+		return fmt.Errorf("unknown check run %d", id)
+	}
+	m.checkRuns[id] = run
+	return nil
+}
+
+// CheckRun returns the most recent state of the check run.
+func (m *MockChecksReporter) CheckRun(id int64) (CheckRun, bool) {
+	run, ok := m.checkRuns[id]
+	return run, ok
+}
+
+// FailWithError configures the reporter to return errors.
+func (m *MockChecksReporter) FailWithError(err error) {
+	m.reportError = err
+}
@@ -4,10 +4,80 @@ import (
 	"context"
 
 	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
 )
 
-// PipelineRunner executes a pipeline by name, creating a PipelineRun with the
-// correct params and resource bindings.
-type PipelineRunner interface {
-	Run(ctx context.Context, pipelineName, ns, serviceAccountName string, params []pipelinev1.Param, res []pipelinev1.PipelineResourceBinding, ws []pipelinev1.WorkspaceBinding) (*pipelinev1.PipelineRun, error)
+// RunKind is the kind of Tekton run that is created.
+type RunKind string
+
+const (
+	// PipelineRunKind executes a Pipeline.
+	PipelineRunKind RunKind = "PipelineRun"
+	// TaskRunKind executes a single Task.
+	TaskRunKind RunKind = "TaskRun"
+)
+
+// Runner executes a Pipeline or Task by name, or an embedded spec if the name
+// is empty, creating a run with the correct params and bindings.
+//
+// Render returns the run that Run would create, without creating it.
+//
+// It can also find, cancel and delete the runs that it created.
+type Runner interface {
+	Run(ctx context.Context, ns string, opts RunOptions) (RunObject, error)
+	Render(ns string, opts RunOptions) (RunObject, error)
+	ListRuns(ctx context.Context, ns string, kind RunKind, apiVersion APIVersion, labels map[string]string) ([]*unstructured.Unstructured, error)
+	Cancel(ctx context.Context, run *unstructured.Unstructured) error
+	Delete(ctx context.Context, run *unstructured.Unstructured) error
+}
+
+// RunObject is a created PipelineRun or TaskRun.
+type RunObject interface {
+	metav1.Object
+	runtime.Object
+}
+
+// ObjectParam is a param with an object value.
+type ObjectParam struct {
+	Name  string
+	Value map[string]string
+}
+
+// RunOptions configures the run that is created.
+type RunOptions struct {
+	// Kind is the kind of run to create, this defaults to a PipelineRun.
+	Kind RunKind
+	// APIVersion is the Tekton API version of the run, this defaults to
+	// v1beta1.
+	APIVersion APIVersion
+	// Name is the name of the Pipeline or Task to execute.
+	Name string
+	// Labels are applied to the run.
+	Labels map[string]string
+	// OwnerReferences are applied to the run, so that it is garbage collected
+	// along with its owners.
+	OwnerReferences    []metav1.OwnerReference
+	ServiceAccountName string
+	Params             []pipelinev1.Param
+	// ObjectParams are added to the run after the Params.
+	ObjectParams []ObjectParam
+	// Resources are only used for PipelineRuns.
+	Resources  []pipelinev1.PipelineResourceBinding
+	Workspaces []pipelinev1.WorkspaceBinding
+	// Template is used as the basis for the PipelineRun if provided.
+	Template *pollingv1.PipelineRunTemplate
+	// Bundle is the Tekton bundle that contains the named Pipeline.
+	Bundle string
+	// Resolver is the remote resolver used to fetch the Pipeline, this is used
+	// instead of the name.
+	Resolver       string
+	ResolverParams []pollingv1.ResolverParam
+	// PipelineSpec is embedded in the PipelineRun if the name is empty.
+	PipelineSpec *pipelinev1.PipelineSpec
+	// TaskSpec is embedded in the TaskRun if the name is empty.
+	TaskSpec *pipelinev1.TaskSpec
 }
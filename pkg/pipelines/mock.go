@@ -7,36 +7,111 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
 )
 
-var _ PipelineRunner = (*MockRunner)(nil)
+var _ Runner = (*MockRunner)(nil)
 
-// NewMockRunner creates and returns a new mock PipelineRunner.
+// NewMockRunner creates and returns a new mock Runner.
 func NewMockRunner(t *testing.T) *MockRunner {
-	return &MockRunner{runs: make(map[string]run), t: t}
+	return &MockRunner{runs: make(map[string]RunOptions), rendered: make(map[string]RunOptions), t: t}
 }
 
-// MockRunner is a mock pipeline runner that returns fixed responses to runs.
+// MockRunner is a mock runner that returns fixed responses to runs.
 type MockRunner struct {
-	t        *testing.T
-	runs     map[string]run
-	runError error
+	t         *testing.T
+	runs      map[string]RunOptions
+	rendered  map[string]RunOptions
+	runError  error
+	existing  []*unstructured.Unstructured
+	cancelled []string
+	deleted   []string
 }
 
-type run struct {
-	serviceAccountName string
-	params             []pipelinev1.Param
-	resources          []pipelinev1.PipelineResourceBinding
-	workspaces         []pipelinev1.WorkspaceBinding
+// Run is an implementation of the Runner interface, the returned run is named
+// for the Pipeline or Task.
+func (m *MockRunner) Run(ctx context.Context, ns string, opts RunOptions) (RunObject, error) {
+	if m.runError != nil {
+		return nil, m.runError
+	}
+	m.runs[mockKey(ns, opts.Name)] = opts
+	meta := metav1.ObjectMeta{Name: opts.Name, Namespace: ns}
+	if opts.Kind == TaskRunKind {
+		return &pipelinev1.TaskRun{ObjectMeta: meta}, nil
+	}
+	return &pipelinev1.PipelineRun{ObjectMeta: meta}, nil
 }
 
-// Run is an implementation of the PipelineRunner interface.
-func (m *MockRunner) Run(ctx context.Context, pipelineName, ns, serviceAccountName string, params []pipelinev1.Param, res []pipelinev1.PipelineResourceBinding, ws []pipelinev1.WorkspaceBinding) (*pipelinev1.PipelineRun, error) {
+// Render is an implementation of the Runner interface, the returned run is
+// named for the Pipeline or Task.
+func (m *MockRunner) Render(ns string, opts RunOptions) (RunObject, error) {
 	if m.runError != nil {
 		return nil, m.runError
 	}
-	m.runs[mockKey(ns, pipelineName)] = run{serviceAccountName: serviceAccountName, params: params, resources: res, workspaces: ws}
-	return &pipelinev1.PipelineRun{}, nil
+	m.rendered[mockKey(ns, opts.Name)] = opts
+	meta := metav1.ObjectMeta{Name: opts.Name, Namespace: ns, Labels: opts.Labels}
+	if opts.Kind == TaskRunKind {
+		return &pipelinev1.TaskRun{TypeMeta: taskRunMeta, ObjectMeta: meta}, nil
+	}
+	return &pipelinev1.PipelineRun{TypeMeta: pipelineRunMeta, ObjectMeta: meta}, nil
+}
+
+// ListRuns is an implementation of the Runner interface, it returns the runs
+// added with AddRuns that match.
+func (m *MockRunner) ListRuns(ctx context.Context, ns string, kind RunKind, apiVersion APIVersion, labels map[string]string) ([]*unstructured.Unstructured, error) {
+	if kind == "" {
+		kind = PipelineRunKind
+	}
+	matches := []*unstructured.Unstructured{}
+	for _, r := range m.existing {
+		if r.GetNamespace() != ns || r.GetKind() != string(kind) {
+			continue
+		}
+		if !k8slabels.SelectorFromSet(labels).Matches(k8slabels.Set(r.GetLabels())) {
+			continue
+		}
+		matches = append(matches, r)
+	}
+	return matches, nil
+}
+
+// Cancel is an implementation of the Runner interface.
+func (m *MockRunner) Cancel(ctx context.Context, run *unstructured.Unstructured) error {
+	m.cancelled = append(m.cancelled, mockKey(run.GetNamespace(), run.GetName()))
+	return nil
+}
+
+// Delete is an implementation of the Runner interface.
+func (m *MockRunner) Delete(ctx context.Context, run *unstructured.Unstructured) error {
+	m.deleted = append(m.deleted, mockKey(run.GetNamespace(), run.GetName()))
+	return nil
+}
+
+// AddRuns adds existing runs that are returned by ListRuns.
+func (m *MockRunner) AddRuns(runs ...*unstructured.Unstructured) {
+	m.existing = append(m.existing, runs...)
+}
+
+// AssertCancelled ensures that the runs, identified by "namespace:name", were
+// cancelled.
+func (m *MockRunner) AssertCancelled(want ...string) {
+	m.t.Helper()
+	if diff := cmp.Diff(want, m.cancelled); diff != "" {
+		m.t.Fatalf("incorrect cancelled runs:\n%s", diff)
+	}
+}
+
+// AssertDeleted ensures that the runs, identified by "namespace:name", were
+// deleted.
+func (m *MockRunner) AssertDeleted(want ...string) {
+	m.t.Helper()
+	if diff := cmp.Diff(want, m.deleted); diff != "" {
+		m.t.Fatalf("incorrect deleted runs:\n%s", diff)
+	}
 }
 
 // AssertPipelineRun ensures that the pipeline run was triggered.
@@ -46,28 +121,78 @@ func (m *MockRunner) AssertPipelineRun(pipelineName, ns string, serviceAccountNa
 	if !ok {
 		m.t.Fatalf("no pipeline run for %s/%s", ns, pipelineName)
 	}
-	if diff := cmp.Diff(wantParams, run.params); diff != "" {
+	if diff := cmp.Diff(wantParams, run.Params); diff != "" {
 		m.t.Fatalf("incorrect params for pipelinerun:\n%s", diff)
 	}
 
-	if diff := cmp.Diff(wantResources, run.resources); diff != "" {
+	if diff := cmp.Diff(wantResources, run.Resources); diff != "" {
 		m.t.Fatalf("incorrect resources for pipeline run:\n%s", diff)
 	}
 
-	if diff := cmp.Diff(serviceAccountName, run.serviceAccountName); diff != "" {
+	if diff := cmp.Diff(serviceAccountName, run.ServiceAccountName); diff != "" {
 		m.t.Fatalf("incorrect serviceAccountName for pipeline run:\n%s", diff)
 	}
 
-	if diff := cmp.Diff(wantWorkspaces, run.workspaces); diff != "" {
+	if diff := cmp.Diff(wantWorkspaces, run.Workspaces); diff != "" {
 		m.t.Fatalf("incorrect workspaces for pipeline run:\n%s", diff)
 	}
 }
 
-// AssertNoPipelineRuns fails if there were any pipelines executed.
-func (m *MockRunner) AssertNoPipelineRuns() {
+// AssertPipelineRunTemplate ensures that the pipeline run was triggered with
+// the template.
+func (m *MockRunner) AssertPipelineRunTemplate(pipelineName, ns string, want *pollingv1.PipelineRunTemplate) {
+	m.t.Helper()
+	run, ok := m.runs[mockKey(ns, pipelineName)]
+	if !ok {
+		m.t.Fatalf("no pipeline run for %s/%s", ns, pipelineName)
+	}
+	if diff := cmp.Diff(want, run.Template); diff != "" {
+		m.t.Fatalf("incorrect template for pipeline run:\n%s", diff)
+	}
+}
+
+// AssertRunOptions ensures that the named pipeline or task was run with the
+// options.
+func (m *MockRunner) AssertRunOptions(name, ns string, want RunOptions) {
+	m.t.Helper()
+	run, ok := m.runs[mockKey(ns, name)]
+	if !ok {
+		m.t.Fatalf("no run for %s/%s", ns, name)
+	}
+	if diff := cmp.Diff(want, run); diff != "" {
+		m.t.Fatalf("incorrect options for run:\n%s", diff)
+	}
+}
+
+// RunOptions returns the options that the named pipeline or task was run
+// with.
+func (m *MockRunner) RunOptions(name, ns string) RunOptions {
+	m.t.Helper()
+	run, ok := m.runs[mockKey(ns, name)]
+	if !ok {
+		m.t.Fatalf("no run for %s/%s", ns, name)
+	}
+	return run
+}
+
+// AssertNoRuns fails if there were any pipelines or tasks executed.
+func (m *MockRunner) AssertNoRuns() {
 	m.t.Helper()
 	if len(m.runs) != 0 {
-		m.t.Fatalf("pipelines were executed: %#v\n", m.runs)
+		m.t.Fatalf("runs were created: %#v\n", m.runs)
+	}
+}
+
+// AssertRendered ensures that the named pipeline or task was rendered with
+// the options.
+func (m *MockRunner) AssertRendered(name, ns string, want RunOptions) {
+	m.t.Helper()
+	run, ok := m.rendered[mockKey(ns, name)]
+	if !ok {
+		m.t.Fatalf("no rendered run for %s/%s", ns, name)
+	}
+	if diff := cmp.Diff(want, run); diff != "" {
+		m.t.Fatalf("incorrect options for rendered run:\n%s", diff)
 	}
 }
 
@@ -76,6 +201,6 @@ func (m *MockRunner) FailWithError(err error) {
 	m.runError = err
 }
 
-func mockKey(ns, pipelineName string) string {
-	return strings.Join([]string{ns, pipelineName}, ":")
+func mockKey(ns, name string) string {
+	return strings.Join([]string{ns, name}, ":")
 }
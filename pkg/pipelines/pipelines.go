@@ -8,58 +8,252 @@ import (
 	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	resourcev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/resource/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
 )
 
-const pipelineRunNames = "polled-pipelinerun-"
+const (
+	pipelineRunNames = "polled-pipelinerun-"
+	taskRunNames     = "polled-taskrun-"
+)
 
 var pipelineRunMeta = metav1.TypeMeta{
 	APIVersion: "tekton.dev/v1beta1",
 	Kind:       "PipelineRun",
 }
 
-// NewRunner creates a new PipelineRunner that creates PipelineRuns with the
-// provided client.
-func NewRunner(c client.Client) *ClientPipelineRunner {
-	return &ClientPipelineRunner{client: c, objectMeta: objectMetaCreator}
+var taskRunMeta = metav1.TypeMeta{
+	APIVersion: "tekton.dev/v1beta1",
+	Kind:       "TaskRun",
+}
+
+// NewRunner creates a new Runner that creates PipelineRuns and TaskRuns with
+// the provided client.
+func NewRunner(c client.Client) *ClientRunner {
+	return &ClientRunner{client: c, objectMeta: objectMetaCreator}
 }
 
-// ClientPipelineRunner uses a split client to run pipelines.
-type ClientPipelineRunner struct {
+// ClientRunner uses a split client to run pipelines and tasks.
+type ClientRunner struct {
 	client     client.Client
-	objectMeta func(string) metav1.ObjectMeta
+	objectMeta func(ns, generateName string) metav1.ObjectMeta
 }
 
-// Run is an implementation of the PipelineRunner interface.
-// TODO: This should replaced by TriggerTemplates/TriggerBindings.
-func (c *ClientPipelineRunner) Run(ctx context.Context, pipelineName, ns, serviceAccountName string, params []pipelinev1.Param, res []pipelinev1.PipelineResourceBinding, ws []pipelinev1.WorkspaceBinding) (*pipelinev1.PipelineRun, error) {
-	pr := c.makePipelineRun(pipelineName, serviceAccountName, ns, params, res, ws)
-	err := c.client.Create(ctx, pr)
+// Run is an implementation of the Runner interface.
+func (c *ClientRunner) Run(ctx context.Context, ns string, opts RunOptions) (RunObject, error) {
+	run, err := c.makeRun(ns, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create a pipeline run for pipeline %s: %w", pipelineName, err)
+		return nil, err
+	}
+	if err := c.client.Create(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to create a %s: %w", describeRun(opts), err)
+	}
+	return run, nil
+}
+
+// Render is an implementation of the Runner interface.
+func (c *ClientRunner) Render(ns string, opts RunOptions) (RunObject, error) {
+	return c.makeRun(ns, opts)
+}
+
+func (c *ClientRunner) makeRun(ns string, opts RunOptions) (RunObject, error) {
+	var run RunObject
+	if opts.Kind == TaskRunKind {
+		run = c.makeTaskRun(ns, opts)
+	} else {
+		pr := c.makePipelineRun(ns, opts)
+		run = pr
+		if opts.Resolver != "" {
+			u, err := withResolver(pr, opts.Resolver, opts.ResolverParams)
+			if err != nil {
+				return nil, err
+			}
+			run = u
+		}
+	}
+	if len(opts.ObjectParams) > 0 {
+		u, err := withObjectParams(run, opts.ObjectParams)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a %s: %w", describeRun(opts), err)
+		}
+		run = u
+	}
+	if opts.APIVersion == V1 {
+		u, err := toV1(run)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a %s: %w", describeRun(opts), err)
+		}
+		run = u
 	}
-	return pr, nil
+	return run, nil
 }
 
-func (c *ClientPipelineRunner) makePipelineRun(pipelineName, serviceAccountName, ns string, params []pipelinev1.Param, res []pipelinev1.PipelineResourceBinding, ws []pipelinev1.WorkspaceBinding) *pipelinev1.PipelineRun {
-	return &pipelinev1.PipelineRun{
+func (c *ClientRunner) makePipelineRun(ns string, opts RunOptions) *pipelinev1.PipelineRun {
+	pr := &pipelinev1.PipelineRun{
 		TypeMeta:   pipelineRunMeta,
-		ObjectMeta: c.objectMeta(ns),
-		Spec: pipelinev1.PipelineRunSpec{
-			PipelineRef:        &pipelinev1.PipelineRef{Name: pipelineName},
-			ServiceAccountName: serviceAccountName,
-			Params:             params,
-			Resources:          applyReplacements(res, params),
-			Workspaces:         ws,
+		ObjectMeta: c.objectMeta(ns, pipelineRunNames),
+	}
+	if opts.Template != nil {
+		tmpl := opts.Template.DeepCopy()
+		pr.ObjectMeta.Labels = tmpl.Metadata.Labels
+		pr.ObjectMeta.Annotations = tmpl.Metadata.Annotations
+		pr.Spec = tmpl.Spec.PipelineRunSpec
+	}
+	if opts.Name == "" && opts.PipelineSpec != nil {
+		pr.Spec.PipelineRef = nil
+		pr.Spec.PipelineSpec = opts.PipelineSpec.DeepCopy()
+	} else {
+		if pr.Spec.PipelineRef == nil {
+			pr.Spec.PipelineRef = &pipelinev1.PipelineRef{}
+		}
+		pr.Spec.PipelineRef.Name = opts.Name
+		pr.Spec.PipelineRef.Bundle = opts.Bundle
+	}
+	pr.ObjectMeta.Labels = mergeLabels(pr.ObjectMeta.Labels, opts.Labels)
+	pr.ObjectMeta.OwnerReferences = opts.OwnerReferences
+	if opts.ServiceAccountName != "" {
+		pr.Spec.ServiceAccountName = opts.ServiceAccountName
+	}
+	pr.Spec.Params = mergeParams(pr.Spec.Params, opts.Params)
+	pr.Spec.Resources = append(pr.Spec.Resources, applyReplacements(opts.Resources, pr.Spec.Params)...)
+	pr.Spec.Workspaces = append(pr.Spec.Workspaces, opts.Workspaces...)
+	return pr
+}
+
+func (c *ClientRunner) makeTaskRun(ns string, opts RunOptions) *pipelinev1.TaskRun {
+	tr := &pipelinev1.TaskRun{
+		TypeMeta:   taskRunMeta,
+		ObjectMeta: c.objectMeta(ns, taskRunNames),
+		Spec: pipelinev1.TaskRunSpec{
+			ServiceAccountName: opts.ServiceAccountName,
+			Params:             opts.Params,
+			Workspaces:         opts.Workspaces,
 		},
 	}
+	tr.ObjectMeta.Labels = mergeLabels(tr.ObjectMeta.Labels, opts.Labels)
+	tr.ObjectMeta.OwnerReferences = opts.OwnerReferences
+	if opts.Name == "" && opts.TaskSpec != nil {
+		tr.Spec.TaskSpec = opts.TaskSpec.DeepCopy()
+	} else {
+		tr.Spec.TaskRef = &pipelinev1.TaskRef{Name: opts.Name}
+	}
+	return tr
+}
+
+// withResolver returns the PipelineRun with a pipelineRef that uses a remote
+// resolver.
+//
+// The vendored Tekton types predate remote resolution, so the PipelineRun is
+// converted to an unstructured object to set the resolver fields.
+func withResolver(pr *pipelinev1.PipelineRun, resolver string, params []pollingv1.ResolverParam) (*unstructured.Unstructured, error) {
+	u, err := toUnstructured(pr)
+	if err != nil {
+		return nil, err
+	}
+	ref := map[string]interface{}{"resolver": resolver}
+	if len(params) > 0 {
+		resolverParams := []interface{}{}
+		for _, p := range params {
+			resolverParams = append(resolverParams, map[string]interface{}{"name": p.Name, "value": p.Value})
+		}
+		ref["params"] = resolverParams
+	}
+	if err := unstructured.SetNestedField(u.Object, ref, "spec", "pipelineRef"); err != nil {
+		return nil, fmt.Errorf("failed to set the pipeline resolver: %w", err)
+	}
+	return u, nil
+}
+
+// withObjectParams returns the run with the object params, replacing any
+// params with the same names.
+//
+// The vendored Tekton types predate object params, so the run is converted to
+// an unstructured object to add them.
+func withObjectParams(run RunObject, objects []ObjectParam) (*unstructured.Unstructured, error) {
+	u, err := toUnstructured(run)
+	if err != nil {
+		return nil, err
+	}
+	existing, _, err := unstructured.NestedSlice(u.Object, "spec", "params")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the params: %w", err)
+	}
+	names := map[string]bool{}
+	for _, o := range objects {
+		names[o.Name] = true
+	}
+	params := []interface{}{}
+	for _, p := range existing {
+		if m, ok := p.(map[string]interface{}); ok && names[fmt.Sprint(m["name"])] {
+			continue
+		}
+		params = append(params, p)
+	}
+	for _, o := range objects {
+		value := map[string]interface{}{}
+		for k, v := range o.Value {
+			value[k] = v
+		}
+		params = append(params, map[string]interface{}{"name": o.Name, "value": value})
+	}
+	if err := unstructured.SetNestedSlice(u.Object, params, "spec", "params"); err != nil {
+		return nil, fmt.Errorf("failed to set the object params: %w", err)
+	}
+	return u, nil
+}
+
+func describeRun(opts RunOptions) string {
+	if opts.Kind == TaskRunKind {
+		if opts.Name == "" {
+			return "task run for the embedded task"
+		}
+		return "task run for task " + opts.Name
+	}
+	if opts.Resolver != "" {
+		return "pipeline run for the " + opts.Resolver + " resolver"
+	}
+	if opts.Name == "" {
+		return "pipeline run for the embedded pipeline"
+	}
+	return "pipeline run for pipeline " + opts.Name
+}
+
+func mergeLabels(existing, labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return existing
+	}
+	if existing == nil {
+		existing = map[string]string{}
+	}
+	for k, v := range labels {
+		existing[k] = v
+	}
+	return existing
+}
+
+// mergeParams returns the params from the template, with any params with the
+// same name replaced by the provided params.
+func mergeParams(tmpl, params []pipelinev1.Param) []pipelinev1.Param {
+	merged := []pipelinev1.Param{}
+	provided := map[string]bool{}
+	for _, p := range params {
+		provided[p.Name] = true
+	}
+	for _, p := range tmpl {
+		if !provided[p.Name] {
+			merged = append(merged, p)
+		}
+	}
+	return append(merged, params...)
 }
 
 // This is here because the controller-runtime fake client doesn't generate
 // names...
-func objectMetaCreator(ns string) metav1.ObjectMeta {
+func objectMetaCreator(ns, generateName string) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
-		GenerateName: pipelineRunNames,
+		GenerateName: generateName,
 		Namespace:    ns,
 	}
 }
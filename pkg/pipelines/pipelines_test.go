@@ -3,36 +3,42 @@ package pipelines
 import (
 	"context"
 	"testing"
+	"time"
 
 	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	resourcev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/resource/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/google/go-cmp/cmp"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
 )
 
 const (
 	testPipelineName       = "test-pipeline"
 	testPipelineRun        = "test-pipeline-run"
+	testTaskName           = "test-task"
+	testTaskRun            = "test-task-run"
 	testRepoURL            = "https://github.com/example/example.git"
 	testSHA                = "35576600886452a3f0f2e9d459924865f4007614"
 	testNamespace          = "test-namespace"
 	testServiceAccountName = "test-sa"
 )
 
-var _ PipelineRunner = (*ClientPipelineRunner)(nil)
+var _ Runner = (*ClientRunner)(nil)
 
 func TestRunPipelineCreatesPipelineRun(t *testing.T) {
 	s := scheme.Scheme
 	s.AddKnownTypes(pipelinev1.SchemeGroupVersion, &pipelinev1.PipelineRun{})
 	cl := fake.NewFakeClient()
 	r := NewRunner(cl)
-	r.objectMeta = func(ns string) metav1.ObjectMeta {
+	r.objectMeta = func(ns, _ string) metav1.ObjectMeta {
 		return metav1.ObjectMeta{
 			Name:      testPipelineRun,
 			Namespace: ns,
@@ -61,7 +67,13 @@ func TestRunPipelineCreatesPipelineRun(t *testing.T) {
 			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "test-pvc"},
 		},
 	}
-	_, err := r.Run(context.Background(), testPipelineName, testNamespace, testServiceAccountName, params, resources, workspaces)
+	_, err := r.Run(context.Background(), testNamespace, RunOptions{
+		Name:               testPipelineName,
+		ServiceAccountName: testServiceAccountName,
+		Params:             params,
+		Resources:          resources,
+		Workspaces:         workspaces,
+	})
 
 	pr := &pipelinev1.PipelineRun{}
 	err = cl.Get(context.Background(), types.NamespacedName{
@@ -109,6 +121,437 @@ func TestRunPipelineCreatesPipelineRun(t *testing.T) {
 	}
 }
 
+func TestRunPipelineWithTemplate(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(pipelinev1.SchemeGroupVersion, &pipelinev1.PipelineRun{})
+	cl := fake.NewFakeClient()
+	r := NewRunner(cl)
+	r.objectMeta = func(ns, _ string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      testPipelineRun,
+			Namespace: ns,
+		}
+	}
+	timeout := &metav1.Duration{Duration: time.Minute * 20}
+	tmpl := &pollingv1.PipelineRunTemplate{
+		Metadata: pollingv1.TemplateMetadata{
+			Labels:      map[string]string{"app": "test"},
+			Annotations: map[string]string{"example.com/annotation": "test"},
+		},
+		Spec: pollingv1.PipelineRunSpec{
+			PipelineRunSpec: pipelinev1.PipelineRunSpec{
+				ServiceAccountName: "template-sa",
+				Timeout:            timeout,
+				Params: []pipelinev1.Param{
+					{Name: "static", Value: *pipelinev1.NewArrayOrString("static-value")},
+					{Name: "test", Value: *pipelinev1.NewArrayOrString("template-value")},
+				},
+				ServiceAccountNames: []pipelinev1.PipelineRunSpecServiceAccountName{
+					{TaskName: "deploy", ServiceAccountName: "deploy-sa"},
+				},
+			},
+		},
+	}
+
+	_, err := r.Run(context.Background(), testNamespace, RunOptions{
+		Name: testPipelineName,
+		Params: []pipelinev1.Param{
+			{Name: "test", Value: *pipelinev1.NewArrayOrString("value")},
+		},
+		Template: tmpl,
+		Labels:   testLabels,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr := &pipelinev1.PipelineRun{}
+	err = cl.Get(context.Background(), types.NamespacedName{
+		Namespace: testNamespace, Name: testPipelineRun,
+	}, pr)
+	if err != nil {
+		t.Fatalf("get pipelinerun: %s", err)
+	}
+
+	want := &pipelinev1.PipelineRun{
+		TypeMeta: pipelineRunMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            testPipelineRun,
+			Namespace:       testNamespace,
+			ResourceVersion: "1",
+			Labels: map[string]string{
+				"app":                           "test",
+				"polling.tekton.dev/repository": "test-repository",
+			},
+			Annotations: map[string]string{"example.com/annotation": "test"},
+		},
+		Spec: pipelinev1.PipelineRunSpec{
+			Params: []pipelinev1.Param{
+				{Name: "static", Value: *pipelinev1.NewArrayOrString("static-value")},
+				{Name: "test", Value: *pipelinev1.NewArrayOrString("value")},
+			},
+			PipelineRef:        &pipelinev1.PipelineRef{Name: testPipelineName},
+			ServiceAccountName: "template-sa",
+			ServiceAccountNames: []pipelinev1.PipelineRunSpecServiceAccountName{
+				{TaskName: "deploy", ServiceAccountName: "deploy-sa"},
+			},
+			Timeout: timeout,
+		},
+	}
+	if diff := cmp.Diff(want, pr); diff != "" {
+		t.Fatalf("got an incorrect PipelineRun back:\n%s", diff)
+	}
+	if tmpl.Spec.Params[1].Value.StringVal != "template-value" {
+		t.Fatal("the template was modified")
+	}
+}
+
+func TestRunPipelineWithPipelineSpec(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(pipelinev1.SchemeGroupVersion, &pipelinev1.PipelineRun{})
+	cl := fake.NewFakeClient()
+	r := NewRunner(cl)
+	r.objectMeta = func(ns, _ string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      testPipelineRun,
+			Namespace: ns,
+		}
+	}
+	spec := &pipelinev1.PipelineSpec{
+		Tasks: []pipelinev1.PipelineTask{
+			{Name: "test-task", TaskRef: &pipelinev1.TaskRef{Name: "test-task"}},
+		},
+	}
+
+	_, err := r.Run(context.Background(), testNamespace, RunOptions{
+		PipelineSpec: spec,
+		Template: &pollingv1.PipelineRunTemplate{
+			Spec: pollingv1.PipelineRunSpec{
+				PipelineRunSpec: pipelinev1.PipelineRunSpec{
+					PipelineRef: &pipelinev1.PipelineRef{Name: "template-pipeline"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr := &pipelinev1.PipelineRun{}
+	err = cl.Get(context.Background(), types.NamespacedName{
+		Namespace: testNamespace, Name: testPipelineRun,
+	}, pr)
+	if err != nil {
+		t.Fatalf("get pipelinerun: %s", err)
+	}
+
+	want := &pipelinev1.PipelineRun{
+		TypeMeta: pipelineRunMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            testPipelineRun,
+			Namespace:       testNamespace,
+			ResourceVersion: "1",
+		},
+		Spec: pipelinev1.PipelineRunSpec{
+			PipelineSpec: spec,
+		},
+	}
+	if diff := cmp.Diff(want, pr); diff != "" {
+		t.Fatalf("got an incorrect PipelineRun back:\n%s", diff)
+	}
+}
+
+func TestRunPipelineWithBundle(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(pipelinev1.SchemeGroupVersion, &pipelinev1.PipelineRun{})
+	cl := fake.NewFakeClient()
+	r := NewRunner(cl)
+	r.objectMeta = func(ns, _ string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      testPipelineRun,
+			Namespace: ns,
+		}
+	}
+
+	_, err := r.Run(context.Background(), testNamespace, RunOptions{
+		Name:   testPipelineName,
+		Bundle: "example.com/pipelines:v1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr := &pipelinev1.PipelineRun{}
+	err = cl.Get(context.Background(), types.NamespacedName{
+		Namespace: testNamespace, Name: testPipelineRun,
+	}, pr)
+	if err != nil {
+		t.Fatalf("get pipelinerun: %s", err)
+	}
+	want := &pipelinev1.PipelineRef{Name: testPipelineName, Bundle: "example.com/pipelines:v1"}
+	if diff := cmp.Diff(want, pr.Spec.PipelineRef); diff != "" {
+		t.Fatalf("got an incorrect PipelineRef:\n%s", diff)
+	}
+}
+
+func TestRunPipelineWithResolver(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(pipelinev1.SchemeGroupVersion, &pipelinev1.PipelineRun{})
+	cl := fake.NewFakeClient()
+	r := NewRunner(cl)
+	r.objectMeta = func(ns, _ string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      testPipelineRun,
+			Namespace: ns,
+		}
+	}
+	params := []pipelinev1.Param{
+		{Name: "test", Value: *pipelinev1.NewArrayOrString("value")},
+	}
+
+	_, err := r.Run(context.Background(), testNamespace, RunOptions{
+		Params:   params,
+		Resolver: "git",
+		ResolverParams: []pollingv1.ResolverParam{
+			{Name: "url", Value: testRepoURL},
+			{Name: "pathInRepo", Value: "pipeline.yaml"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr := &unstructured.Unstructured{}
+	pr.SetGroupVersionKind(pipelinev1.SchemeGroupVersion.WithKind("PipelineRun"))
+	err = cl.Get(context.Background(), types.NamespacedName{
+		Namespace: testNamespace, Name: testPipelineRun,
+	}, pr)
+	if err != nil {
+		t.Fatalf("get pipelinerun: %s", err)
+	}
+	ref, _, err := unstructured.NestedMap(pr.Object, "spec", "pipelineRef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRef := map[string]interface{}{
+		"resolver": "git",
+		"params": []interface{}{
+			map[string]interface{}{"name": "url", "value": testRepoURL},
+			map[string]interface{}{"name": "pathInRepo", "value": "pipeline.yaml"},
+		},
+	}
+	if diff := cmp.Diff(wantRef, ref); diff != "" {
+		t.Fatalf("got an incorrect pipelineRef:\n%s", diff)
+	}
+	runParams, _, err := unstructured.NestedSlice(pr.Object, "spec", "params")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantParams := []interface{}{
+		map[string]interface{}{"name": "test", "value": "value"},
+	}
+	if diff := cmp.Diff(wantParams, runParams); diff != "" {
+		t.Fatalf("got incorrect params:\n%s", diff)
+	}
+}
+
+func TestRenderPipelineWithObjectParams(t *testing.T) {
+	r := NewRunner(fake.NewFakeClient())
+
+	run, err := r.Render(testNamespace, RunOptions{
+		Name:   testPipelineName,
+		Params: []pipelinev1.Param{{Name: "test", Value: *pipelinev1.NewArrayOrString("value")}},
+		ObjectParams: []ObjectParam{
+			{Name: "source", Value: map[string]string{"url": testRepoURL, "revision": testSHA}},
+		},
+		Template: &pollingv1.PipelineRunTemplate{
+			Spec: pollingv1.PipelineRunSpec{
+				PipelineRunSpec: pipelinev1.PipelineRunSpec{
+					Params: []pipelinev1.Param{
+						{Name: "source", Value: *pipelinev1.NewArrayOrString("replaced")},
+						{Name: "kept", Value: *pipelinev1.NewArrayOrString("template")},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runParams, _, err := unstructured.NestedSlice(run.(*unstructured.Unstructured).Object, "spec", "params")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantParams := []interface{}{
+		map[string]interface{}{"name": "kept", "value": "template"},
+		map[string]interface{}{"name": "test", "value": "value"},
+		map[string]interface{}{"name": "source", "value": map[string]interface{}{"url": testRepoURL, "revision": testSHA}},
+	}
+	if diff := cmp.Diff(wantParams, runParams); diff != "" {
+		t.Fatalf("got incorrect params:\n%s", diff)
+	}
+}
+
+var testOwnerReferences = []metav1.OwnerReference{
+	{
+		APIVersion: "polling.tekton.dev/v1alpha1",
+		Kind:       "Repository",
+		Name:       "test-repository",
+		UID:        "d1cf7a5e-4f3b-4b0e-9f40-5c1c3c9b6a2e",
+	},
+}
+
+func TestRunTaskCreatesTaskRun(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(pipelinev1.SchemeGroupVersion, &pipelinev1.TaskRun{})
+	cl := fake.NewFakeClient()
+	r := NewRunner(cl)
+	r.objectMeta = func(ns, generateName string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:         testTaskRun,
+			GenerateName: generateName,
+			Namespace:    ns,
+		}
+	}
+	params := []pipelinev1.Param{
+		{Name: "test", Value: *pipelinev1.NewArrayOrString("value")},
+	}
+	workspaces := []pipelinev1.WorkspaceBinding{
+		{
+			Name:                  "test-workspace",
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "test-pvc"},
+		},
+	}
+
+	_, err := r.Run(context.Background(), testNamespace, RunOptions{
+		Kind:               TaskRunKind,
+		Name:               testTaskName,
+		Labels:             testLabels,
+		OwnerReferences:    testOwnerReferences,
+		ServiceAccountName: testServiceAccountName,
+		Params:             params,
+		Workspaces:         workspaces,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &pipelinev1.TaskRun{}
+	err = cl.Get(context.Background(), types.NamespacedName{
+		Namespace: testNamespace, Name: testTaskRun,
+	}, tr)
+	if err != nil {
+		t.Fatalf("get taskrun: %s", err)
+	}
+	want := &pipelinev1.TaskRun{
+		TypeMeta: taskRunMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            testTaskRun,
+			GenerateName:    taskRunNames,
+			Namespace:       testNamespace,
+			ResourceVersion: "1",
+			Labels:          testLabels,
+			OwnerReferences: testOwnerReferences,
+		},
+		Spec: pipelinev1.TaskRunSpec{
+			Params:             params,
+			TaskRef:            &pipelinev1.TaskRef{Name: testTaskName},
+			ServiceAccountName: testServiceAccountName,
+			Workspaces:         workspaces,
+		},
+	}
+	if diff := cmp.Diff(want, tr); diff != "" {
+		t.Fatalf("got an incorrect TaskRun back:\n%s", diff)
+	}
+}
+
+func TestRunTaskWithTaskSpec(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(pipelinev1.SchemeGroupVersion, &pipelinev1.TaskRun{})
+	cl := fake.NewFakeClient()
+	r := NewRunner(cl)
+	r.objectMeta = func(ns, _ string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      testTaskRun,
+			Namespace: ns,
+		}
+	}
+	spec := &pipelinev1.TaskSpec{
+		Steps: []pipelinev1.Step{
+			{Container: corev1.Container{Name: "lint", Image: "golangci/golangci-lint"}},
+		},
+	}
+
+	_, err := r.Run(context.Background(), testNamespace, RunOptions{
+		Kind:     TaskRunKind,
+		TaskSpec: spec,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &pipelinev1.TaskRun{}
+	err = cl.Get(context.Background(), types.NamespacedName{
+		Namespace: testNamespace, Name: testTaskRun,
+	}, tr)
+	if err != nil {
+		t.Fatalf("get taskrun: %s", err)
+	}
+	want := &pipelinev1.TaskRun{
+		TypeMeta: taskRunMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            testTaskRun,
+			Namespace:       testNamespace,
+			ResourceVersion: "1",
+		},
+		Spec: pipelinev1.TaskRunSpec{
+			TaskSpec: spec,
+		},
+	}
+	if diff := cmp.Diff(want, tr); diff != "" {
+		t.Fatalf("got an incorrect TaskRun back:\n%s", diff)
+	}
+}
+
+func TestRenderDoesNotCreateRun(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(pipelinev1.SchemeGroupVersion, &pipelinev1.TaskRun{}, &pipelinev1.TaskRunList{})
+	cl := fake.NewFakeClient()
+	r := NewRunner(cl)
+
+	run, err := r.Render(testNamespace, RunOptions{
+		Kind:   TaskRunKind,
+		Name:   testTaskName,
+		Params: []pipelinev1.Param{{Name: "test", Value: *pipelinev1.NewArrayOrString("value")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &pipelinev1.TaskRun{
+		TypeMeta: taskRunMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: taskRunNames,
+			Namespace:    testNamespace,
+		},
+		Spec: pipelinev1.TaskRunSpec{
+			Params:  []pipelinev1.Param{{Name: "test", Value: *pipelinev1.NewArrayOrString("value")}},
+			TaskRef: &pipelinev1.TaskRef{Name: testTaskName},
+		},
+	}
+	if diff := cmp.Diff(want, run); diff != "" {
+		t.Fatalf("got an incorrect TaskRun back:\n%s", diff)
+	}
+	trs := &pipelinev1.TaskRunList{}
+	if err := cl.List(context.Background(), trs); err != nil {
+		t.Fatal(err)
+	}
+	if l := len(trs.Items); l != 0 {
+		t.Fatalf("Render() created %d TaskRuns, want 0", l)
+	}
+}
+
 func TestApplyReplacements(t *testing.T) {
 	resources := []pipelinev1.PipelineResourceBinding{
 		{
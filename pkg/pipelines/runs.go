@@ -0,0 +1,100 @@
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ListRuns is an implementation of the Runner interface.
+//
+// The runs are returned as unstructured objects, so that v1 runs can be listed.
+func (c *ClientRunner) ListRuns(ctx context.Context, ns string, kind RunKind, apiVersion APIVersion, labels map[string]string) ([]*unstructured.Unstructured, error) {
+	if kind == "" {
+		kind = PipelineRunKind
+	}
+	if apiVersion == "" {
+		apiVersion = V1Beta1
+	}
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{Group: tektonGroup, Version: string(apiVersion), Kind: string(kind) + "List"})
+	if err := c.client.List(ctx, list, client.InNamespace(ns), client.MatchingLabels(labels)); err != nil {
+		return nil, fmt.Errorf("failed to list %ss in %s: %w", kind, ns, err)
+	}
+	runs := []*unstructured.Unstructured{}
+	for i := range list.Items {
+		runs = append(runs, &list.Items[i])
+	}
+	return runs, nil
+}
+
+// Cancel is an implementation of the Runner interface.
+func (c *ClientRunner) Cancel(ctx context.Context, run *unstructured.Unstructured) error {
+	patch := []byte(fmt.Sprintf(`{"spec":{"status":%q}}`, cancelStatus(run)))
+	if err := c.client.Patch(ctx, run, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return fmt.Errorf("failed to cancel %s %s/%s: %w", run.GetKind(), run.GetNamespace(), run.GetName(), err)
+	}
+	return nil
+}
+
+// Delete is an implementation of the Runner interface.
+func (c *ClientRunner) Delete(ctx context.Context, run *unstructured.Unstructured) error {
+	if err := c.client.Delete(ctx, run, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete %s %s/%s: %w", run.GetKind(), run.GetNamespace(), run.GetName(), err)
+	}
+	return nil
+}
+
+// IsDone returns true if the run has completed, successfully or not.
+func IsDone(run *unstructured.Unstructured) bool {
+	status, ok := succeededStatus(run)
+	return ok && status != "Unknown"
+}
+
+// IsSucceeded returns true if the run completed successfully.
+func IsSucceeded(run *unstructured.Unstructured) bool {
+	status, ok := succeededStatus(run)
+	return ok && status == "True"
+}
+
+// CompletionTime returns the time that the run completed, or the zero time if
+// it hasn't completed.
+func CompletionTime(run *unstructured.Unstructured) time.Time {
+	s, _, _ := unstructured.NestedString(run.Object, "status", "completionTime")
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func succeededStatus(run *unstructured.Unstructured) (string, bool) {
+	conditions, _, _ := unstructured.NestedSlice(run.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["type"] != "Succeeded" {
+			continue
+		}
+		status, ok := cond["status"].(string)
+		return status, ok
+	}
+	return "", false
+}
+
+func cancelStatus(run *unstructured.Unstructured) string {
+	if run.GetKind() == string(TaskRunKind) {
+		return pipelinev1.TaskRunSpecStatusCancelled
+	}
+	if run.GetAPIVersion() == tektonGroup+"/"+string(V1) {
+		return "Cancelled"
+	}
+	return pipelinev1.PipelineRunSpecStatusCancelled
+}
@@ -0,0 +1,166 @@
+package pipelines
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var testLabels = map[string]string{"polling.tekton.dev/repository": "test-repository"}
+
+func TestListRuns(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(pipelinev1.SchemeGroupVersion, &pipelinev1.PipelineRun{}, &pipelinev1.PipelineRunList{})
+	cl := fake.NewFakeClientWithScheme(s,
+		makePipelineRun("labelled", testNamespace, testLabels),
+		makePipelineRun("unlabelled", testNamespace, nil),
+		makePipelineRun("other-namespace", "other-namespace", testLabels),
+	)
+	r := NewRunner(cl)
+
+	runs, err := r.ListRuns(context.Background(), testNamespace, PipelineRunKind, V1Beta1, testLabels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, r := range runs {
+		names = append(names, r.GetName())
+	}
+	if diff := cmp.Diff([]string{"labelled"}, names); diff != "" {
+		t.Fatalf("ListRuns() returned incorrect runs:\n%s", diff)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(pipelinev1.SchemeGroupVersion, &pipelinev1.PipelineRun{}, &pipelinev1.PipelineRunList{})
+	cl := fake.NewFakeClientWithScheme(s, makePipelineRun("active", testNamespace, testLabels))
+	r := NewRunner(cl)
+	runs, err := r.ListRuns(context.Background(), testNamespace, PipelineRunKind, V1Beta1, testLabels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Cancel(context.Background(), runs[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	pr := &pipelinev1.PipelineRun{}
+	err = cl.Get(context.Background(), types.NamespacedName{Name: "active", Namespace: testNamespace}, pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Spec.Status != pipelinev1.PipelineRunSpecStatusCancelled {
+		t.Fatalf("got status %q, want %q", pr.Spec.Status, pipelinev1.PipelineRunSpecStatusCancelled)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(pipelinev1.SchemeGroupVersion, &pipelinev1.PipelineRun{}, &pipelinev1.PipelineRunList{})
+	cl := fake.NewFakeClientWithScheme(s, makePipelineRun("completed", testNamespace, testLabels))
+	r := NewRunner(cl)
+	runs, err := r.ListRuns(context.Background(), testNamespace, PipelineRunKind, V1Beta1, testLabels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Delete(context.Background(), runs[0]); err != nil {
+		t.Fatal(err)
+	}
+	// Deleting a run that has already gone isn't an error.
+	if err := r.Delete(context.Background(), runs[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	pr := &pipelinev1.PipelineRun{}
+	err = cl.Get(context.Background(), types.NamespacedName{Name: "completed", Namespace: testNamespace}, pr)
+	if !errors.IsNotFound(err) {
+		t.Fatalf("got error %v, want not found", err)
+	}
+}
+
+func TestCancelStatus(t *testing.T) {
+	statusTests := []struct {
+		apiVersion string
+		kind       string
+		want       string
+	}{
+		{"tekton.dev/v1beta1", "PipelineRun", "PipelineRunCancelled"},
+		{"tekton.dev/v1", "PipelineRun", "Cancelled"},
+		{"tekton.dev/v1beta1", "TaskRun", "TaskRunCancelled"},
+		{"tekton.dev/v1", "TaskRun", "TaskRunCancelled"},
+	}
+
+	for _, tt := range statusTests {
+		run := &unstructured.Unstructured{}
+		run.SetAPIVersion(tt.apiVersion)
+		run.SetKind(tt.kind)
+		if s := cancelStatus(run); s != tt.want {
+			t.Errorf("cancelStatus(%s %s) got %q, want %q", tt.apiVersion, tt.kind, s, tt.want)
+		}
+	}
+}
+
+func TestIsDone(t *testing.T) {
+	doneTests := []struct {
+		name       string
+		conditions []interface{}
+		want       bool
+	}{
+		{"no conditions", nil, false},
+		{"running", []interface{}{map[string]interface{}{"type": "Succeeded", "status": "Unknown"}}, false},
+		{"succeeded", []interface{}{map[string]interface{}{"type": "Succeeded", "status": "True"}}, true},
+		{"failed", []interface{}{map[string]interface{}{"type": "Succeeded", "status": "False"}}, true},
+	}
+
+	for _, tt := range doneTests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			if tt.conditions != nil {
+				run.Object["status"] = map[string]interface{}{"conditions": tt.conditions}
+			}
+			if d := IsDone(run); d != tt.want {
+				t.Fatalf("IsDone() got %v, want %v", d, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSucceeded(t *testing.T) {
+	run := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions":     []interface{}{map[string]interface{}{"type": "Succeeded", "status": "True"}},
+			"completionTime": "2020-10-01T10:00:00Z",
+		},
+	}}
+
+	if !IsSucceeded(run) {
+		t.Fatal("IsSucceeded() got false, want true")
+	}
+	want := time.Date(2020, time.October, 1, 10, 0, 0, 0, time.UTC)
+	if c := CompletionTime(run); !c.Equal(want) {
+		t.Fatalf("CompletionTime() got %s, want %s", c, want)
+	}
+}
+
+func makePipelineRun(name, ns string, labels map[string]string) *pipelinev1.PipelineRun {
+	return &pipelinev1.PipelineRun{
+		TypeMeta: pipelineRunMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels:    labels,
+		},
+	}
+}
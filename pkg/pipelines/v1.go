@@ -0,0 +1,208 @@
+package pipelines
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+)
+
+// APIVersion is the version of the Tekton API used for created runs.
+type APIVersion string
+
+const (
+	// V1Beta1 creates tekton.dev/v1beta1 runs.
+	V1Beta1 APIVersion = "v1beta1"
+	// V1 creates tekton.dev/v1 runs.
+	V1 APIVersion = "v1"
+	// AutoAPIVersion detects the newest version that the cluster serves with
+	// DetectAPIVersion.
+	AutoAPIVersion APIVersion = "auto"
+)
+
+const tektonGroup = "tekton.dev"
+
+// DetectAPIVersion returns the newest Tekton API version that is served by
+// the cluster.
+func DetectAPIVersion(d discovery.DiscoveryInterface) (APIVersion, error) {
+	groups, err := d.ServerGroups()
+	if err != nil {
+		return "", fmt.Errorf("failed to discover the Tekton API versions: %w", err)
+	}
+	for _, g := range groups.Groups {
+		if g.Name != tektonGroup {
+			continue
+		}
+		for _, v := range g.Versions {
+			if v.Version == string(V1) {
+				return V1, nil
+			}
+		}
+	}
+	return V1Beta1, nil
+}
+
+// toV1 converts a v1beta1 PipelineRun or TaskRun to the tekton.dev/v1 layout.
+func toV1(run runtime.Object) (*unstructured.Unstructured, error) {
+	u, err := toUnstructured(run)
+	if err != nil {
+		return nil, err
+	}
+	u.SetAPIVersion(tektonGroup + "/" + string(V1))
+	spec, ok := u.Object["spec"].(map[string]interface{})
+	if !ok {
+		return u, nil
+	}
+	switch u.GetKind() {
+	case string(PipelineRunKind):
+		err = pipelineRunSpecToV1(spec)
+	case string(TaskRunKind):
+		err = taskRunSpecToV1(spec)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u, nil
+	}
+	converted, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the run: %w", err)
+	}
+	return &unstructured.Unstructured{Object: converted}, nil
+}
+
+// In v1, the service account and pod template are in the taskRunTemplate, the
+// timeout is in timeouts, and the deprecated serviceAccountNames are replaced
+// by taskRunSpecs.
+func pipelineRunSpecToV1(spec map[string]interface{}) error {
+	if _, ok := spec["resources"]; ok {
+		return errors.New("resources are not supported by tekton.dev/v1 PipelineRuns")
+	}
+	if ref, ok := spec["pipelineRef"].(map[string]interface{}); ok {
+		bundleToResolver(ref, "pipeline")
+	}
+	template := map[string]interface{}{}
+	moveField(spec, "serviceAccountName", template, "serviceAccountName")
+	moveField(spec, "podTemplate", template, "podTemplate")
+	if len(template) > 0 {
+		spec["taskRunTemplate"] = template
+	}
+	if timeout, ok := spec["timeout"]; ok {
+		delete(spec, "timeout")
+		spec["timeouts"] = map[string]interface{}{"pipeline": timeout}
+	}
+	taskRunSpecs, _ := spec["taskRunSpecs"].([]interface{})
+	for _, s := range taskRunSpecs {
+		if m, ok := s.(map[string]interface{}); ok {
+			moveField(m, "taskServiceAccountName", m, "serviceAccountName")
+			moveField(m, "taskPodTemplate", m, "podTemplate")
+		}
+	}
+	names, _ := spec["serviceAccountNames"].([]interface{})
+	for _, s := range names {
+		if m, ok := s.(map[string]interface{}); ok {
+			taskRunSpecs = append(taskRunSpecs, map[string]interface{}{
+				"pipelineTaskName":   m["taskName"],
+				"serviceAccountName": m["serviceAccountName"],
+			})
+		}
+	}
+	delete(spec, "serviceAccountNames")
+	if len(taskRunSpecs) > 0 {
+		spec["taskRunSpecs"] = taskRunSpecs
+	}
+	if ps, ok := spec["pipelineSpec"].(map[string]interface{}); ok {
+		return pipelineSpecToV1(ps)
+	}
+	return nil
+}
+
+func pipelineSpecToV1(spec map[string]interface{}) error {
+	if _, ok := spec["resources"]; ok {
+		return errors.New("pipeline resources are not supported by tekton.dev/v1 Pipelines")
+	}
+	for _, key := range []string{"tasks", "finally"} {
+		tasks, _ := spec[key].([]interface{})
+		for _, t := range tasks {
+			task, ok := t.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, unsupported := range []string{"resources", "conditions"} {
+				if _, ok := task[unsupported]; ok {
+					return fmt.Errorf("pipeline task %v: %s are not supported by tekton.dev/v1 Pipelines", task["name"], unsupported)
+				}
+			}
+			if ref, ok := task["taskRef"].(map[string]interface{}); ok {
+				bundleToResolver(ref, "task")
+			}
+			if ts, ok := task["taskSpec"].(map[string]interface{}); ok {
+				if err := taskSpecToV1(ts); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func taskRunSpecToV1(spec map[string]interface{}) error {
+	if _, ok := spec["resources"]; ok {
+		return errors.New("resources are not supported by tekton.dev/v1 TaskRuns")
+	}
+	if ts, ok := spec["taskSpec"].(map[string]interface{}); ok {
+		return taskSpecToV1(ts)
+	}
+	return nil
+}
+
+// In v1, the container resources for steps and sidecars are computeResources.
+func taskSpecToV1(spec map[string]interface{}) error {
+	if _, ok := spec["resources"]; ok {
+		return errors.New("task resources are not supported by tekton.dev/v1 Tasks")
+	}
+	for _, key := range []string{"steps", "sidecars"} {
+		containers, _ := spec[key].([]interface{})
+		for _, c := range containers {
+			if m, ok := c.(map[string]interface{}); ok {
+				moveField(m, "resources", m, "computeResources")
+			}
+		}
+	}
+	if tmpl, ok := spec["stepTemplate"].(map[string]interface{}); ok {
+		moveField(tmpl, "resources", tmpl, "computeResources")
+	}
+	return nil
+}
+
+// In v1, bundles are referenced through the bundles resolver.
+func bundleToResolver(ref map[string]interface{}, kind string) {
+	bundle, ok := ref["bundle"]
+	if !ok {
+		return
+	}
+	ref["resolver"] = "bundles"
+	ref["params"] = []interface{}{
+		map[string]interface{}{"name": "bundle", "value": bundle},
+		map[string]interface{}{"name": "name", "value": ref["name"]},
+		map[string]interface{}{"name": "kind", "value": kind},
+	}
+	delete(ref, "bundle")
+	delete(ref, "name")
+}
+
+func moveField(from map[string]interface{}, fromKey string, to map[string]interface{}, toKey string) {
+	v, ok := from[fromKey]
+	if !ok {
+		return
+	}
+	delete(from, fromKey)
+	to[toKey] = v
+}
@@ -0,0 +1,222 @@
+package pipelines
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	resourcev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/resource/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+)
+
+func TestDetectAPIVersion(t *testing.T) {
+	detectTests := []struct {
+		name      string
+		resources []*metav1.APIResourceList
+		want      APIVersion
+	}{
+		{"no tekton", nil, V1Beta1},
+		{
+			"v1beta1",
+			[]*metav1.APIResourceList{
+				{GroupVersion: "tekton.dev/v1beta1", APIResources: []metav1.APIResource{{Name: "pipelineruns"}}},
+			},
+			V1Beta1,
+		},
+		{
+			"v1 and v1beta1",
+			[]*metav1.APIResourceList{
+				{GroupVersion: "tekton.dev/v1beta1", APIResources: []metav1.APIResource{{Name: "pipelineruns"}}},
+				{GroupVersion: "tekton.dev/v1", APIResources: []metav1.APIResource{{Name: "pipelineruns"}}},
+			},
+			V1,
+		},
+	}
+
+	for _, tt := range detectTests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: tt.resources}}
+			v, err := DetectAPIVersion(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != tt.want {
+				t.Fatalf("DetectAPIVersion() got %v, want %v", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunPipelineWithV1(t *testing.T) {
+	cl := fake.NewFakeClient()
+	r := NewRunner(cl)
+	r.objectMeta = func(ns, _ string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      testPipelineRun,
+			Namespace: ns,
+		}
+	}
+
+	_, err := r.Run(context.Background(), testNamespace, RunOptions{
+		APIVersion:         V1,
+		Name:               testPipelineName,
+		ServiceAccountName: testServiceAccountName,
+		Params: []pipelinev1.Param{
+			{Name: "test", Value: *pipelinev1.NewArrayOrString("value")},
+		},
+		Template: &pollingv1.PipelineRunTemplate{
+			Spec: pollingv1.PipelineRunSpec{
+				PipelineRunSpec: pipelinev1.PipelineRunSpec{
+					Timeout: &metav1.Duration{Duration: time.Minute * 20},
+					PodTemplate: &pipelinev1.PodTemplate{
+						NodeSelector: map[string]string{"disktype": "ssd"},
+					},
+					TaskRunSpecs: []pipelinev1.PipelineTaskRunSpec{
+						{PipelineTaskName: "build", TaskServiceAccountName: "build-sa"},
+					},
+					ServiceAccountNames: []pipelinev1.PipelineRunSpecServiceAccountName{
+						{TaskName: "deploy", ServiceAccountName: "deploy-sa"},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr := &unstructured.Unstructured{}
+	pr.SetAPIVersion("tekton.dev/v1")
+	pr.SetKind("PipelineRun")
+	err = cl.Get(context.Background(), types.NamespacedName{
+		Namespace: testNamespace, Name: testPipelineRun,
+	}, pr)
+	if err != nil {
+		t.Fatalf("get pipelinerun: %s", err)
+	}
+	want := map[string]interface{}{
+		"pipelineRef": map[string]interface{}{"name": testPipelineName},
+		"params": []interface{}{
+			map[string]interface{}{"name": "test", "value": "value"},
+		},
+		"taskRunTemplate": map[string]interface{}{
+			"serviceAccountName": testServiceAccountName,
+			"podTemplate": map[string]interface{}{
+				"nodeSelector": map[string]interface{}{"disktype": "ssd"},
+			},
+		},
+		"timeouts": map[string]interface{}{"pipeline": "20m0s"},
+		"taskRunSpecs": []interface{}{
+			map[string]interface{}{"pipelineTaskName": "build", "serviceAccountName": "build-sa"},
+			map[string]interface{}{"pipelineTaskName": "deploy", "serviceAccountName": "deploy-sa"},
+		},
+	}
+	if diff := cmp.Diff(want, pr.Object["spec"]); diff != "" {
+		t.Fatalf("got an incorrect PipelineRun spec:\n%s", diff)
+	}
+}
+
+func TestToV1(t *testing.T) {
+	pr := &pipelinev1.PipelineRun{
+		TypeMeta: pipelineRunMeta,
+		Spec: pipelinev1.PipelineRunSpec{
+			PipelineRef: &pipelinev1.PipelineRef{Name: testPipelineName, Bundle: "example.com/pipelines:v1"},
+		},
+	}
+
+	u, err := toV1(pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := u.GetAPIVersion(); v != "tekton.dev/v1" {
+		t.Fatalf("got apiVersion %q, want tekton.dev/v1", v)
+	}
+	want := map[string]interface{}{
+		"resolver": "bundles",
+		"params": []interface{}{
+			map[string]interface{}{"name": "bundle", "value": "example.com/pipelines:v1"},
+			map[string]interface{}{"name": "name", "value": testPipelineName},
+			map[string]interface{}{"name": "kind", "value": "pipeline"},
+		},
+	}
+	ref, _, err := unstructured.NestedMap(u.Object, "spec", "pipelineRef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, ref); diff != "" {
+		t.Fatalf("got an incorrect pipelineRef:\n%s", diff)
+	}
+}
+
+func TestToV1WithTaskRun(t *testing.T) {
+	tr := &pipelinev1.TaskRun{
+		TypeMeta: taskRunMeta,
+		Spec: pipelinev1.TaskRunSpec{
+			TaskSpec: &pipelinev1.TaskSpec{
+				Steps: []pipelinev1.Step{
+					{
+						Container: corev1.Container{
+							Name: "lint",
+							Resources: corev1.ResourceRequirements{
+								Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	u, err := toV1(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps, _, err := unstructured.NestedSlice(u.Object, "spec", "taskSpec", "steps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		map[string]interface{}{
+			"name": "lint",
+			"computeResources": map[string]interface{}{
+				"limits": map[string]interface{}{"memory": "1Gi"},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, steps); diff != "" {
+		t.Fatalf("got incorrect steps:\n%s", diff)
+	}
+}
+
+func TestToV1WithResources(t *testing.T) {
+	pr := &pipelinev1.PipelineRun{
+		TypeMeta: pipelineRunMeta,
+		Spec: pipelinev1.PipelineRunSpec{
+			PipelineRef: &pipelinev1.PipelineRef{Name: testPipelineName},
+			Resources: []pipelinev1.PipelineResourceBinding{
+				{Name: "testing", ResourceSpec: &resourcev1alpha1.PipelineResourceSpec{Type: "git"}},
+			},
+		},
+	}
+
+	_, err := toV1(pr)
+
+	wantErr := "resources are not supported by tekton.dev/v1 PipelineRuns"
+	if err == nil || !regexp.MustCompile(wantErr).MatchString(err.Error()) {
+		t.Fatalf("toV1() got error %v, want %s", err, wantErr)
+	}
+}
@@ -0,0 +1,4 @@
+package triggers
+
+// This package provides functionality for rendering Tekton Triggers
+// TriggerTemplates, and creating the resources they declare.
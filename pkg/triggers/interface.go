@@ -0,0 +1,29 @@
+package triggers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TemplateRunner renders the named TriggerTemplate with the params, and
+// creates the resources that it declares.
+//
+// RenderTemplate returns the resources that Run would create, without
+// creating them.
+type TemplateRunner interface {
+	Run(ctx context.Context, template types.NamespacedName, params map[string]string, opts RunOptions) ([]*unstructured.Unstructured, error)
+	RenderTemplate(ctx context.Context, template types.NamespacedName, params map[string]string) ([]*unstructured.Unstructured, error)
+}
+
+// RunOptions configures the resources that are created from a
+// TriggerTemplate.
+type RunOptions struct {
+	// Labels are applied to every created resource.
+	Labels map[string]string
+	// SkipExisting looks for each resource with the Labels before creating
+	// it, this is used to retry a TriggerTemplate that was partially created,
+	// so the Labels must identify the attempt.
+	SkipExisting bool
+}
@@ -0,0 +1,108 @@
+package triggers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ TemplateRunner = (*MockRunner)(nil)
+
+// NewMockRunner creates and returns a new mock TemplateRunner.
+func NewMockRunner(t *testing.T) *MockRunner {
+	return &MockRunner{
+		runs:     make(map[types.NamespacedName]map[string]string),
+		options:  make(map[types.NamespacedName]RunOptions),
+		rendered: make(map[types.NamespacedName]map[string]string),
+		t:        t,
+	}
+}
+
+// MockRunner is a mock template runner that records the rendered templates.
+type MockRunner struct {
+	t         *testing.T
+	runs      map[types.NamespacedName]map[string]string
+	options   map[types.NamespacedName]RunOptions
+	rendered  map[types.NamespacedName]map[string]string
+	resources []*unstructured.Unstructured
+	runError  error
+}
+
+// Run is an implementation of the TemplateRunner interface.
+func (m *MockRunner) Run(ctx context.Context, template types.NamespacedName, params map[string]string, opts RunOptions) ([]*unstructured.Unstructured, error) {
+	if m.runError != nil {
+		return nil, m.runError
+	}
+	m.runs[template] = params
+	m.options[template] = opts
+	return []*unstructured.Unstructured{}, nil
+}
+
+// RenderTemplate is an implementation of the TemplateRunner interface, it
+// returns the resources added with AddResources.
+func (m *MockRunner) RenderTemplate(ctx context.Context, template types.NamespacedName, params map[string]string) ([]*unstructured.Unstructured, error) {
+	if m.runError != nil {
+		return nil, m.runError
+	}
+	m.rendered[template] = params
+	return m.resources, nil
+}
+
+// AssertTemplateRun ensures that the template was rendered with the params.
+func (m *MockRunner) AssertTemplateRun(template types.NamespacedName, want map[string]string) {
+	m.t.Helper()
+	params, ok := m.runs[template]
+	if !ok {
+		m.t.Fatalf("no run for trigger template %s", template)
+	}
+	if diff := cmp.Diff(want, params); diff != "" {
+		m.t.Fatalf("incorrect params for trigger template:\n%s", diff)
+	}
+}
+
+// AssertTemplateRunOptions ensures that the template was run with the
+// options.
+func (m *MockRunner) AssertTemplateRunOptions(template types.NamespacedName, want RunOptions) {
+	m.t.Helper()
+	opts, ok := m.options[template]
+	if !ok {
+		m.t.Fatalf("no run for trigger template %s", template)
+	}
+	if diff := cmp.Diff(want, opts); diff != "" {
+		m.t.Fatalf("incorrect options for trigger template:\n%s", diff)
+	}
+}
+
+// AssertNoTemplateRuns fails if there were any templates rendered.
+func (m *MockRunner) AssertNoTemplateRuns() {
+	m.t.Helper()
+	if len(m.runs) != 0 {
+		m.t.Fatalf("trigger templates were rendered: %#v\n", m.runs)
+	}
+}
+
+// AssertTemplateRendered ensures that the template was rendered with the
+// params, without creating the resources.
+func (m *MockRunner) AssertTemplateRendered(template types.NamespacedName, want map[string]string) {
+	m.t.Helper()
+	params, ok := m.rendered[template]
+	if !ok {
+		m.t.Fatalf("trigger template %s was not rendered", template)
+	}
+	if diff := cmp.Diff(want, params); diff != "" {
+		m.t.Fatalf("incorrect params for trigger template:\n%s", diff)
+	}
+}
+
+// AddResources adds resources that are returned by RenderTemplate.
+func (m *MockRunner) AddResources(resources ...*unstructured.Unstructured) {
+	m.resources = append(m.resources, resources...)
+}
+
+// FailWithError configures the runner to return errors.
+func (m *MockRunner) FailWithError(err error) {
+	m.runError = err
+}
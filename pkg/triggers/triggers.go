@@ -0,0 +1,206 @@
+package triggers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+)
+
+// The TriggerTemplate versions that are supported, in order of preference.
+var triggerTemplateGVKs = []schema.GroupVersionKind{
+	{Group: "triggers.tekton.dev", Version: "v1beta1", Kind: "TriggerTemplate"},
+	{Group: "triggers.tekton.dev", Version: "v1alpha1", Kind: "TriggerTemplate"},
+}
+
+// NewRunner creates a new TemplateRunner that creates resources with the
+// provided client.
+func NewRunner(c client.Client) *ClientTemplateRunner {
+	return &ClientTemplateRunner{client: c}
+}
+
+// ClientTemplateRunner uses a split client to fetch TriggerTemplates and create
+// resources.
+//
+// The templates are fetched as unstructured objects, so this doesn't depend on
+// the Tekton Triggers types.
+type ClientTemplateRunner struct {
+	client client.Client
+}
+
+// Run is an implementation of the TemplateRunner interface.
+//
+// Resources without a namespace are created in the namespace of the
+// TriggerTemplate, and each resource is labelled with its index in the
+// template, so that it can be found if the template is retried.
+func (c *ClientTemplateRunner) Run(ctx context.Context, template types.NamespacedName, params map[string]string, opts RunOptions) ([]*unstructured.Unstructured, error) {
+	resources, err := c.RenderTemplate(ctx, template, params)
+	if err != nil {
+		return nil, err
+	}
+	created := []*unstructured.Unstructured{}
+	for i, r := range resources {
+		labels := r.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		for k, v := range opts.Labels {
+			labels[k] = v
+		}
+		labels[pollingv1.TemplateResourceLabel] = strconv.Itoa(i)
+		r.SetLabels(labels)
+		if opts.SkipExisting {
+			existing, err := c.findExisting(ctx, r)
+			if err != nil {
+				return created, fmt.Errorf("failed to find an existing %s from trigger template %s: %w", r.GetKind(), template, err)
+			}
+			if existing != nil {
+				created = append(created, existing)
+				continue
+			}
+		}
+		if err := c.client.Create(ctx, r); err != nil {
+			return created, fmt.Errorf("failed to create a %s from trigger template %s: %w", r.GetKind(), template, err)
+		}
+		created = append(created, r)
+	}
+	return created, nil
+}
+
+// findExisting returns the resource of the same kind with the same labels as
+// the rendered resource, or nil if there's no resource.
+func (c *ClientTemplateRunner) findExisting(ctx context.Context, r *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	list := &unstructured.UnstructuredList{}
+	gvk := r.GroupVersionKind()
+	gvk.Kind = gvk.Kind + "List"
+	list.SetGroupVersionKind(gvk)
+	if err := c.client.List(ctx, list, client.InNamespace(r.GetNamespace()), client.MatchingLabels(r.GetLabels())); err != nil {
+		return nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, nil
+	}
+	return &list.Items[0], nil
+}
+
+// RenderTemplate is an implementation of the TemplateRunner interface.
+//
+// Resources without a namespace are rendered in the namespace of the
+// TriggerTemplate.
+func (c *ClientTemplateRunner) RenderTemplate(ctx context.Context, template types.NamespacedName, params map[string]string) ([]*unstructured.Unstructured, error) {
+	tt, err := c.getTemplate(ctx, template)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := Render(tt, params)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resources {
+		if r.GetNamespace() == "" {
+			r.SetNamespace(template.Namespace)
+		}
+	}
+	return resources, nil
+}
+
+func (c *ClientTemplateRunner) getTemplate(ctx context.Context, template types.NamespacedName) (*unstructured.Unstructured, error) {
+	var err error
+	for _, gvk := range triggerTemplateGVKs {
+		tt := &unstructured.Unstructured{}
+		tt.SetGroupVersionKind(gvk)
+		err = c.client.Get(ctx, template, tt)
+		if err == nil {
+			return tt, nil
+		}
+		if !meta.IsNoMatchError(err) {
+			break
+		}
+	}
+	return nil, fmt.Errorf("failed to get trigger template %s: %w", template, err)
+}
+
+// Render returns the resources declared in the TriggerTemplate, with the
+// $(tt.params.<name>) placeholders replaced by the param values.
+//
+// Params that are declared in the template but not provided use the default
+// from the template.
+func Render(tt *unstructured.Unstructured, params map[string]string) ([]*unstructured.Unstructured, error) {
+	values, err := paramValues(tt, params)
+	if err != nil {
+		return nil, err
+	}
+	templates, _, err := unstructured.NestedSlice(tt.Object, "spec", "resourcetemplates")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the resource templates in trigger template %s: %w", tt.GetName(), err)
+	}
+	resources := []*unstructured.Unstructured{}
+	for _, t := range templates {
+		b, err := json.Marshal(t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal a resource template in trigger template %s: %w", tt.GetName(), err)
+		}
+		rendered := applyParams(string(b), values)
+		r := &unstructured.Unstructured{}
+		if err := r.UnmarshalJSON([]byte(rendered)); err != nil {
+			return nil, fmt.Errorf("failed to parse a rendered resource template in trigger template %s: %w", tt.GetName(), err)
+		}
+		resources = append(resources, r)
+	}
+	return resources, nil
+}
+
+func paramValues(tt *unstructured.Unstructured, params map[string]string) (map[string]string, error) {
+	specs, _, err := unstructured.NestedSlice(tt.Object, "spec", "params")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the params in trigger template %s: %w", tt.GetName(), err)
+	}
+	values := map[string]string{}
+	for _, s := range specs {
+		spec, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := spec["name"].(string)
+		if v, ok := params[name]; ok {
+			values[name] = v
+			continue
+		}
+		def, ok := spec["default"].(string)
+		if !ok {
+			return nil, fmt.Errorf("no value provided for param %#v in trigger template %s", name, tt.GetName())
+		}
+		values[name] = def
+	}
+	return values, nil
+}
+
+// applyParams replaces the $(tt.params.<name>) placeholders in s with the
+// values, in a single pass, so that placeholders in the values are not
+// replaced, and the result doesn't depend on the order of the values.
+//
+// The values are escaped so that they can be safely inserted into the JSON
+// strings.
+func applyParams(s string, values map[string]string) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	replacements := make([]string, 0, len(values)*2)
+	for _, name := range names {
+		escaped, _ := json.Marshal(values[name])
+		replacements = append(replacements, fmt.Sprintf("$(tt.params.%s)", name), string(escaped[1:len(escaped)-1]))
+	}
+	return strings.NewReplacer(replacements...).Replace(s)
+}
@@ -0,0 +1,272 @@
+package triggers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pollingv1 "github.com/bigkevmcd/tekton-polling-operator/pkg/apis/polling/v1alpha1"
+)
+
+const (
+	testNamespace = "test-namespace"
+	testSHA       = "35576600886452a3f0f2e9d459924865f4007614"
+)
+
+var testTemplate = types.NamespacedName{Name: "test-template", Namespace: testNamespace}
+
+func TestRender(t *testing.T) {
+	tt := makeTriggerTemplate()
+
+	resources, err := Render(tt, map[string]string{
+		"sha":     testSHA,
+		"message": `a "quoted" message`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*unstructured.Unstructured{
+		{
+			Object: map[string]interface{}{
+				"apiVersion": "tekton.dev/v1beta1",
+				"kind":       "PipelineRun",
+				"metadata": map[string]interface{}{
+					"generateName": "test-run-",
+				},
+				"spec": map[string]interface{}{
+					"pipelineRef": map[string]interface{}{"name": "test-pipeline"},
+					"params": []interface{}{
+						map[string]interface{}{"name": "sha", "value": testSHA},
+						map[string]interface{}{"name": "message", "value": `a "quoted" message`},
+						map[string]interface{}{"name": "env", "value": "staging"},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, resources); diff != "" {
+		t.Fatalf("Render() got incorrect resources:\n%s", diff)
+	}
+}
+
+func TestApplyParams(t *testing.T) {
+	paramTests := []struct {
+		name   string
+		s      string
+		values map[string]string
+		want   string
+	}{
+		{"no placeholders", `{"value":"testing"}`, map[string]string{"sha": testSHA}, `{"value":"testing"}`},
+		{"placeholders", `{"value":"$(tt.params.sha) $(tt.params.message)"}`,
+			map[string]string{"sha": testSHA, "message": "testing"}, `{"value":"` + testSHA + ` testing"}`},
+		{"escaped values", `{"value":"$(tt.params.message)"}`,
+			map[string]string{"message": `a "quoted" message`}, `{"value":"a \"quoted\" message"}`},
+		{"placeholders in values", `{"value":"$(tt.params.a) $(tt.params.b)"}`,
+			map[string]string{"a": "$(tt.params.b)", "b": "$(tt.params.a)"}, `{"value":"$(tt.params.b) $(tt.params.a)"}`},
+	}
+
+	for _, tt := range paramTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyParams(tt.s, tt.values); got != tt.want {
+				t.Fatalf("applyParams() got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderWithMissingParam(t *testing.T) {
+	tt := makeTriggerTemplate()
+
+	_, err := Render(tt, map[string]string{"sha": testSHA})
+
+	wantErr := `no value provided for param "message" in trigger template test-template`
+	if err == nil || !regexp.MustCompile(wantErr).MatchString(err.Error()) {
+		t.Fatalf("Render() got error %v, want %s", err, wantErr)
+	}
+}
+
+func TestRun(t *testing.T) {
+	cl := fake.NewFakeClient(makeTriggerTemplate())
+	r := NewRunner(cl)
+
+	created, err := r.Run(context.Background(), testTemplate, map[string]string{
+		"sha":     testSHA,
+		"message": "testing",
+	}, RunOptions{Labels: map[string]string{pollingv1.TriggerIDLabel: "test-id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(created); l != 1 {
+		t.Fatalf("Run() created %d resources, want 1", l)
+	}
+	pr := &unstructured.Unstructured{}
+	pr.SetAPIVersion("tekton.dev/v1beta1")
+	pr.SetKind("PipelineRun")
+	err = cl.Get(context.Background(), types.NamespacedName{Name: created[0].GetName(), Namespace: testNamespace}, pr)
+	if err != nil {
+		t.Fatalf("get pipelinerun: %s", err)
+	}
+	params, _, err := unstructured.NestedSlice(pr.Object, "spec", "params")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		map[string]interface{}{"name": "sha", "value": testSHA},
+		map[string]interface{}{"name": "message", "value": "testing"},
+		map[string]interface{}{"name": "env", "value": "staging"},
+	}
+	if diff := cmp.Diff(want, params); diff != "" {
+		t.Fatalf("created PipelineRun has incorrect params:\n%s", diff)
+	}
+	wantLabels := map[string]string{
+		pollingv1.TriggerIDLabel:        "test-id",
+		pollingv1.TemplateResourceLabel: "0",
+	}
+	if diff := cmp.Diff(wantLabels, pr.GetLabels()); diff != "" {
+		t.Fatalf("created PipelineRun has incorrect labels:\n%s", diff)
+	}
+}
+
+func TestRunSkipsExistingResources(t *testing.T) {
+	tt := makeTriggerTemplate()
+	templates, _, _ := unstructured.NestedSlice(tt.Object, "spec", "resourcetemplates")
+	second := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "test-config"},
+	}
+	_ = unstructured.SetNestedSlice(tt.Object, append(templates, second), "spec", "resourcetemplates")
+	existing := &pipelinev1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-run-existing",
+			Namespace: testNamespace,
+			Labels: map[string]string{
+				pollingv1.TriggerIDLabel:        "test-id",
+				pollingv1.TemplateResourceLabel: "0",
+			},
+		},
+	}
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := pipelinev1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	cl := fake.NewFakeClientWithScheme(s, tt, existing)
+	r := NewRunner(cl)
+
+	created, err := r.Run(context.Background(), testTemplate, map[string]string{
+		"sha":     testSHA,
+		"message": "testing",
+	}, RunOptions{Labels: map[string]string{pollingv1.TriggerIDLabel: "test-id"}, SkipExisting: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, c := range created {
+		names = append(names, c.GetKind()+":"+c.GetName())
+	}
+	if diff := cmp.Diff([]string{"PipelineRun:test-run-existing", "ConfigMap:test-config"}, names); diff != "" {
+		t.Fatalf("Run() returned incorrect resources:\n%s", diff)
+	}
+	runs := &unstructured.UnstructuredList{}
+	runs.SetAPIVersion("tekton.dev/v1beta1")
+	runs.SetKind("PipelineRunList")
+	if err := cl.List(context.Background(), runs, client.InNamespace(testNamespace)); err != nil {
+		t.Fatal(err)
+	}
+	if l := len(runs.Items); l != 1 {
+		t.Fatalf("got %d PipelineRuns, want 1", l)
+	}
+}
+
+func TestRunWithMissingTemplate(t *testing.T) {
+	cl := fake.NewFakeClient()
+	r := NewRunner(cl)
+
+	_, err := r.Run(context.Background(), testTemplate, map[string]string{}, RunOptions{})
+
+	wantErr := "failed to get trigger template test-namespace/test-template"
+	if err == nil || !regexp.MustCompile(wantErr).MatchString(err.Error()) {
+		t.Fatalf("Run() got error %v, want %s", err, wantErr)
+	}
+}
+
+func makeTriggerTemplate() *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "triggers.tekton.dev/v1beta1",
+			"kind":       "TriggerTemplate",
+			"metadata": map[string]interface{}{
+				"name":      testTemplate.Name,
+				"namespace": testTemplate.Namespace,
+			},
+			"spec": map[string]interface{}{
+				"params": []interface{}{
+					map[string]interface{}{"name": "sha"},
+					map[string]interface{}{"name": "message"},
+					map[string]interface{}{"name": "env", "default": "staging"},
+				},
+				"resourcetemplates": []interface{}{
+					map[string]interface{}{
+						"apiVersion": "tekton.dev/v1beta1",
+						"kind":       "PipelineRun",
+						"metadata": map[string]interface{}{
+							"generateName": "test-run-",
+						},
+						"spec": map[string]interface{}{
+							"pipelineRef": map[string]interface{}{"name": "test-pipeline"},
+							"params": []interface{}{
+								map[string]interface{}{"name": "sha", "value": "$(tt.params.sha)"},
+								map[string]interface{}{"name": "message", "value": "$(tt.params.message)"},
+								map[string]interface{}{"name": "env", "value": "$(tt.params.env)"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestRenderTemplateDoesNotCreateResources(t *testing.T) {
+	cl := fake.NewFakeClient(makeTriggerTemplate())
+	r := NewRunner(cl)
+
+	rendered, err := r.RenderTemplate(context.Background(), testTemplate, map[string]string{
+		"sha":     testSHA,
+		"message": "testing",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(rendered); l != 1 {
+		t.Fatalf("RenderTemplate() rendered %d resources, want 1", l)
+	}
+	if ns := rendered[0].GetNamespace(); ns != testNamespace {
+		t.Fatalf("RenderTemplate() got namespace %q, want %q", ns, testNamespace)
+	}
+	pr := &unstructured.Unstructured{}
+	pr.SetAPIVersion("tekton.dev/v1beta1")
+	pr.SetKind("PipelineRun")
+	err = cl.Get(context.Background(), types.NamespacedName{Name: rendered[0].GetName(), Namespace: testNamespace}, pr)
+	if !errors.IsNotFound(err) {
+		t.Fatalf("RenderTemplate() created a PipelineRun, got error %v", err)
+	}
+}